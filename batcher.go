@@ -0,0 +1,150 @@
+package manana
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrorKeyNotLoaded is the error of the futures returned by a Batcher whose key is not contained in
+// the result of the batch function.
+var ErrorKeyNotLoaded = errors.New("the batch function did not return a value for the key")
+
+// BatcherConfig specifies when the batches of a Batcher are started.
+type BatcherConfig struct {
+	// Window is the maximum time a key waits for other keys before its batch is started. Default:
+	// 1 millisecond.
+	Window time.Duration
+	// MaxBatch is the maximum number of different keys in a batch. When it is reached, the batch
+	// is started without waiting for the window to end. If zero, the batches are not limited.
+	MaxBatch int
+}
+
+// Batcher collects the keys requested individually within a time window, and loads them with a
+// single invocation of a batch function, to avoid invoking a backend once per key.
+type Batcher struct {
+	config    BatcherConfig
+	batchFunc func(keys []interface{}) (map[interface{}]interface{}, error)
+	// mutex protects the pending batch, and the contents of the batches that have not started
+	mutex   sync.Mutex
+	pending *batch
+}
+
+type batch struct {
+	// keys are kept in request order, without duplicates
+	keys     []interface{}
+	promises map[interface{}][]Promise
+	timer    *time.Timer
+}
+
+// NewBatcher creates a Batcher that loads the keys with the passed batch function. The batch
+// function returns a map with a value for each key. If the value for a key is an error, the
+// futures of the key fail with it. If the batch function returns an error, the futures of all the
+// keys in the batch fail with it.
+func NewBatcher(batchFunc func(keys []interface{}) (map[interface{}]interface{}, error), config BatcherConfig) *Batcher {
+	if config.Window <= 0 {
+		config.Window = time.Millisecond
+	}
+	return &Batcher{config: config, batchFunc: batchFunc}
+}
+
+// Load returns a Future that completes with the value of the key, once it is loaded as part of a
+// batch. Keys requested more than once in the same batch are loaded only once. Canceling the
+// returned Future before its batch starts removes the key from the batch, unless it was also
+// requested by another non-canceled Future.
+func (b *Batcher) Load(key interface{}) Future {
+	p := NewPromise()
+	b.mutex.Lock()
+	current := b.pending
+	if current == nil {
+		current = &batch{promises: map[interface{}][]Promise{}}
+		current.timer = time.AfterFunc(b.config.Window, func() {
+			b.mutex.Lock()
+			started := b.pending == current
+			if started {
+				b.pending = nil
+			}
+			b.mutex.Unlock()
+			if started {
+				b.run(current)
+			}
+		})
+		b.pending = current
+	}
+	if _, ok := current.promises[key]; !ok {
+		current.keys = append(current.keys, key)
+	}
+	current.promises[key] = append(current.promises[key], p)
+	full := b.config.MaxBatch > 0 && len(current.keys) >= b.config.MaxBatch
+	if full {
+		current.timer.Stop()
+		b.pending = nil
+	}
+	b.mutex.Unlock()
+	if full {
+		b.run(current)
+	}
+	p.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			b.drop(current, key, p)
+		}
+	})
+	return p
+}
+
+// run invokes the batch function in background, and completes the futures of the batch with its
+// result
+func (b *Batcher) run(bt *batch) {
+	if len(bt.keys) == 0 {
+		return
+	}
+	Do(func() (interface{}, error) {
+		return b.batchFunc(bt.keys)
+	}).OnComplete(func(result interface{}, err error) {
+		var values map[interface{}]interface{}
+		if err == nil {
+			values = result.(map[interface{}]interface{})
+		}
+		for key, promises := range bt.promises {
+			for _, p := range promises {
+				if err != nil {
+					p.Fail(err)
+				} else if value, ok := values[key]; !ok {
+					p.Fail(ErrorKeyNotLoaded)
+				} else if valueErr, ok := value.(error); ok {
+					p.Fail(valueErr)
+				} else {
+					p.Success(value)
+				}
+			}
+		}
+	})
+}
+
+// drop removes a canceled future from its batch, if it has not started yet. If no futures are left
+// for the key, the key is removed from the batch.
+func (b *Batcher) drop(bt *batch, key interface{}, p Promise) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.pending != bt {
+		return
+	}
+	promises := bt.promises[key]
+	for i, other := range promises {
+		if other == p {
+			promises = append(promises[:i], promises[i+1:]...)
+			break
+		}
+	}
+	if len(promises) > 0 {
+		bt.promises[key] = promises
+		return
+	}
+	delete(bt.promises, key)
+	for i, other := range bt.keys {
+		if other == key {
+			bt.keys = append(bt.keys[:i], bt.keys[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package manana
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingBatch returns a batch function that records the batches it receives and returns the
+// keys multiplied by 10, or an error for the negative keys
+func recordingBatch(mutex *sync.Mutex, batches *[][]interface{}) func(keys []interface{}) (map[interface{}]interface{}, error) {
+	return func(keys []interface{}) (map[interface{}]interface{}, error) {
+		mutex.Lock()
+		*batches = append(*batches, keys)
+		mutex.Unlock()
+		values := map[interface{}]interface{}{}
+		for _, key := range keys {
+			if key.(int) < 0 {
+				values[key] = fmt.Errorf("invalid key %v", key)
+			} else if key.(int) != 0 {
+				values[key] = key.(int) * 10
+			}
+		}
+		return values, nil
+	}
+}
+
+func TestBatcher_Window(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a batcher
+		var mutex sync.Mutex
+		var batches [][]interface{}
+		b := NewBatcher(recordingBatch(&mutex, &batches), BatcherConfig{Window: 10 * time.Millisecond})
+
+		// When many keys are requested within the time window
+		f1, f2, f3, f4 := b.Load(1), b.Load(2), b.Load(1), b.Load(-1)
+		f5 := b.Load(0)
+
+		// They are loaded in a single batch, without duplicates
+		val, err := All(f1, f2, f3).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{10, 20, 10}, val)
+		mutex.Lock()
+		assert.Equal(t, [][]interface{}{{1, 2, -1, 0}}, batches)
+		mutex.Unlock()
+
+		// And each future gets its own value or error
+		_, err = f4.Get()
+		assert.EqualError(t, err, "invalid key -1")
+		_, err = f5.Get()
+		assert.Equal(t, ErrorKeyNotLoaded, err)
+	}))
+}
+
+func TestBatcher_MaxBatch(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a batcher with a maximum batch size
+		var mutex sync.Mutex
+		var batches [][]interface{}
+		b := NewBatcher(recordingBatch(&mutex, &batches), BatcherConfig{Window: time.Hour, MaxBatch: 2})
+
+		// When more keys than the maximum size are requested
+		f1, f2 := b.Load(1), b.Load(2)
+
+		// The batch is started when it is full, without waiting for the window
+		val, err := All(f1, f2).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{10, 20}, val)
+
+		// And the rest of keys are loaded in another batch
+		f3, f4 := b.Load(3), b.Load(4)
+		_, err = All(f3, f4).Get()
+		assert.NoError(t, err)
+		mutex.Lock()
+		assert.Equal(t, [][]interface{}{{1, 2}, {3, 4}}, batches)
+		mutex.Unlock()
+	}))
+}
+
+func TestBatcher_BatchError(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a batch function that fails
+		b := NewBatcher(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			return nil, errors.New("catapun")
+		}, BatcherConfig{})
+
+		// All the futures of the batch fail
+		_, err := b.Load(1).Get()
+		assert.EqualError(t, err, "catapun")
+	}))
+}
+
+func TestBatcher_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a batch that has not started
+		var mutex sync.Mutex
+		var batches [][]interface{}
+		b := NewBatcher(recordingBatch(&mutex, &batches), BatcherConfig{Window: 100 * time.Millisecond})
+		f1, f2, f3 := b.Load(1), b.Load(2), b.Load(3)
+		f3bis := b.Load(3)
+
+		// When some of its futures are canceled
+		assert.NoError(t, f2.Cancel())
+		assert.NoError(t, f3.Cancel())
+
+		// Their keys are removed from the batch, unless other futures requested them
+		val, err := All(f1, f3bis).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{10, 30}, val)
+		mutex.Lock()
+		assert.Equal(t, [][]interface{}{{1, 3}}, batches)
+		mutex.Unlock()
+	}))
+}
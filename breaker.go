@@ -0,0 +1,263 @@
+package manana
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorCircuitOpen is the error of the futures returned by a CircuitBreaker whose circuit is open.
+var ErrorCircuitOpen = errors.New("the circuit is open")
+
+// CircuitState is the state of the circuit of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all the functions run, while their failures are tracked.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen makes all the functions immediately fail with ErrorCircuitOpen, without running.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe functions run to decide whether the circuit
+	// has to be closed or open again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig specifies the behavior of a CircuitBreaker. Zero values are replaced by
+// the documented defaults.
+type CircuitBreakerConfig struct {
+	// Window is the duration of the sliding window where the results of the functions are
+	// tracked. Default: 10 seconds.
+	Window time.Duration
+	// FailureRatio is the ratio of failed functions in the window, between 0 and 1, that opens
+	// the circuit. Default: 0.5.
+	FailureRatio float64
+	// MinCalls is the minimum number of functions in the window to evaluate the failure ratio.
+	// Default: 1.
+	MinCalls int
+	// OpenTimeout is the time the circuit stays open before letting probe functions run.
+	// Default: 30 seconds.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probe functions that can run in the half-open state. If all
+	// of them succeed, the circuit is closed. If any fails, the circuit is open again. Default: 1.
+	HalfOpenProbes int
+	// IsFailure decides whether an error counts as a failure. If nil, all errors but ErrorCanceled
+	// are failures. Canceled functions are never counted.
+	IsFailure func(err error) bool
+	// OnStateChange, if not nil, is invoked on each state change of the circuit, in order, from
+	// a background goroutine.
+	OnStateChange func(from, to CircuitState)
+	// Clock provides the time to the CircuitBreaker. Default: SystemClock.
+	Clock Clock
+}
+
+// CircuitBreaker runs functions in background, as Do and DoCtx do, tracking their failures over a
+// sliding window. When the ratio of failures exceeds a threshold, the circuit opens and the
+// following functions are not run: their futures immediately fail with ErrorCircuitOpen. After a
+// timeout, the circuit becomes half-open and lets a limited number of probe functions run, to
+// decide whether it has to be closed again.
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	events Dispatcher
+	// mutex protects the following fields
+	mutex    sync.Mutex
+	state    CircuitState
+	window   []callResult
+	failures int
+	openedAt time.Time
+	probes   int
+	probeOKs int
+	// halfOpens counts the transitions to the half-open state, to discard the results of the
+	// probes from previous half-open states
+	halfOpens int
+}
+
+type callResult struct {
+	at     time.Time
+	failed bool
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with a closed circuit.
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.FailureRatio <= 0 {
+		config.FailureRatio = 0.5
+	}
+	if config.MinCalls <= 0 {
+		config.MinCalls = 1
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+	return &CircuitBreaker{config: config, events: NewSerialDispatcher()}
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if cb.state == CircuitOpen && cb.config.Clock.Now().Sub(cb.openedAt) >= cb.config.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// Do runs a synchronous function in background, as manana.Do does, unless the circuit is open.
+func (cb *CircuitBreaker) Do(syncFunc func() (interface{}, error)) Future {
+	return cb.DoCtx(func(_ <-chan struct{}) (interface{}, error) {
+		return syncFunc()
+	})
+}
+
+// DoCtx runs a cancelable function in background, as manana.DoCtx does, unless the circuit is
+// open.
+func (cb *CircuitBreaker) DoCtx(asyncFunc func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	probe, halfOpen, err := cb.acquire()
+	if err != nil {
+		p := NewPromise()
+		p.Fail(err)
+		return p
+	}
+	// started is set either when the function starts, or when the future completes before, so the
+	// probe slot of a function that never runs (e.g. canceled or rejected) is given back
+	var started int32
+	f := DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+		if !atomic.CompareAndSwapInt32(&started, 0, 1) {
+			return nil, ErrorCanceled
+		}
+		value, err := safeCall(func() (interface{}, error) {
+			return asyncFunc(cancelCtx)
+		})
+		cb.record(probe, halfOpen, err)
+		return value, err
+	})
+	f.OnComplete(func(_ interface{}, _ error) {
+		if atomic.CompareAndSwapInt32(&started, 0, 1) {
+			cb.release(probe, halfOpen)
+		}
+	})
+	return f
+}
+
+// acquire decides whether a function can run, and whether it is a probe of the current half-open
+// state
+func (cb *CircuitBreaker) acquire() (probe bool, halfOpen int, err error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if cb.config.Clock.Now().Sub(cb.openedAt) < cb.config.OpenTimeout {
+			return false, 0, ErrorCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.probes, cb.probeOKs = 0, 0
+		cb.halfOpens++
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.probes >= cb.config.HalfOpenProbes {
+			return false, 0, ErrorCircuitOpen
+		}
+		cb.probes++
+		return true, cb.halfOpens, nil
+	}
+	return false, 0, nil
+}
+
+// record tracks the result of a function, changing the state of the circuit if required
+func (cb *CircuitBreaker) record(probe bool, halfOpen int, err error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	now := cb.config.Clock.Now()
+	currentProbe := cb.isCurrentProbe(probe, halfOpen)
+	if err == ErrorCanceled {
+		if currentProbe {
+			cb.probes--
+		}
+		return
+	}
+	failed := err != nil && (cb.config.IsFailure == nil || cb.config.IsFailure(err))
+	if probe {
+		if !currentProbe {
+			return
+		}
+		if failed {
+			cb.open(now)
+		} else if cb.probeOKs++; cb.probeOKs >= cb.config.HalfOpenProbes {
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+	// ignoring functions that started before the circuit was open
+	if cb.state != CircuitClosed {
+		return
+	}
+	cb.window = append(cb.window, callResult{at: now, failed: failed})
+	if failed {
+		cb.failures++
+	}
+	for len(cb.window) > 0 && now.Sub(cb.window[0].at) >= cb.config.Window {
+		if cb.window[0].failed {
+			cb.failures--
+		}
+		cb.window = cb.window[1:]
+	}
+	if len(cb.window) >= cb.config.MinCalls &&
+		float64(cb.failures) >= cb.config.FailureRatio*float64(len(cb.window)) {
+		cb.open(now)
+	}
+}
+
+// release gives back the slot of a probe whose function did not run. Other functions that did not
+// run are not tracked.
+func (cb *CircuitBreaker) release(probe bool, halfOpen int) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if cb.isCurrentProbe(probe, halfOpen) {
+		cb.probes--
+	}
+}
+
+// isCurrentProbe ignores the probes from previous half-open states. It must be invoked with the
+// mutex locked
+func (cb *CircuitBreaker) isCurrentProbe(probe bool, halfOpen int) bool {
+	return probe && cb.state == CircuitHalfOpen && halfOpen == cb.halfOpens
+}
+
+// open must be invoked with the mutex locked
+func (cb *CircuitBreaker) open(now time.Time) {
+	cb.openedAt = now
+	cb.window, cb.failures = nil, 0
+	cb.setState(CircuitOpen)
+}
+
+// setState must be invoked with the mutex locked
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	from := cb.state
+	if from == state {
+		return
+	}
+	cb.state = state
+	if cb.config.OnStateChange != nil {
+		cb.events.Dispatch(func() { cb.config.OnStateChange(from, state) })
+	}
+}
@@ -0,0 +1,247 @@
+package manana
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// manualClock is a Clock whose time only changes when it is advanced
+type manualClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []manualTimer
+}
+
+type manualTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: time.Unix(0, 0)}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.timers = append(c.timers, manualTimer{at: c.now.Add(d), ch: ch})
+	}
+	return ch
+}
+
+// Timers returns the number of timers that have not fired yet
+func (c *manualClock) Timers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+// Advance moves the clock forward, firing the timers whose time is reached
+func (c *manualClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.ch <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+func failing() (interface{}, error) {
+	return nil, errors.New("catapun")
+}
+
+func succeeding() (interface{}, error) {
+	return "ok", nil
+}
+
+func TestCircuitBreaker_Opens(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a circuit breaker that opens when half of the calls fail
+		clock := newManualClock()
+		cb := NewCircuitBreaker(CircuitBreakerConfig{
+			Window: time.Minute, FailureRatio: 0.5, MinCalls: 4, Clock: clock,
+		})
+
+		// When the failures do not reach the threshold
+		for _, fn := range []func() (interface{}, error){succeeding, failing, succeeding} {
+			cb.Do(fn).Get()
+		}
+		// The circuit stays closed
+		assert.Equal(t, CircuitClosed, cb.State())
+
+		// When the failures reach the threshold
+		_, err := cb.Do(failing).Get()
+		assert.EqualError(t, err, "catapun")
+
+		// The circuit opens and the functions are not run
+		assert.Equal(t, CircuitOpen, cb.State())
+		run := false
+		_, err = cb.Do(func() (interface{}, error) {
+			run = true
+			return nil, nil
+		}).Get()
+		assert.Equal(t, ErrorCircuitOpen, err)
+		assert.False(t, run)
+	}))
+}
+
+func TestCircuitBreaker_SlidingWindow(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a circuit breaker with a sliding window
+		clock := newManualClock()
+		cb := NewCircuitBreaker(CircuitBreakerConfig{
+			Window: time.Minute, FailureRatio: 0.5, MinCalls: 2, Clock: clock,
+		})
+
+		// When the old failures leave the window
+		cb.Do(failing).Get()
+		clock.Advance(2 * time.Minute)
+		cb.Do(succeeding).Get()
+		cb.Do(succeeding).Get()
+
+		// They are not counted anymore
+		cb.Do(failing).Get()
+		assert.Equal(t, CircuitClosed, cb.State())
+	}))
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given an open circuit breaker
+		clock := newManualClock()
+		var mutex sync.Mutex
+		var changes []string
+		allChanges := make(chan struct{})
+		cb := NewCircuitBreaker(CircuitBreakerConfig{
+			OpenTimeout: 30 * time.Second, HalfOpenProbes: 2, Clock: clock,
+			OnStateChange: func(from, to CircuitState) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				changes = append(changes, from.String()+"->"+to.String())
+				if len(changes) == 5 {
+					close(allChanges)
+				}
+			},
+		})
+		cb.Do(failing).Get()
+		assert.Equal(t, CircuitOpen, cb.State())
+
+		// When the open timeout expires
+		clock.Advance(30 * time.Second)
+		assert.Equal(t, CircuitHalfOpen, cb.State())
+
+		// A limited number of probes are allowed
+		release := make(chan struct{})
+		probe := func() (interface{}, error) {
+			<-release
+			return nil, errors.New("still failing")
+		}
+		p1, p2 := cb.Do(probe), cb.Do(probe)
+		_, err := cb.Do(succeeding).Get()
+		assert.Equal(t, ErrorCircuitOpen, err)
+
+		// And a failed probe opens the circuit again
+		close(release)
+		_, err = p1.Get()
+		assert.Error(t, err)
+		assert.Equal(t, CircuitOpen, cb.State())
+		AllSettled(p1, p2).Get()
+
+		// When all the probes succeed after the open timeout
+		clock.Advance(30 * time.Second)
+		cb.Do(succeeding).Get()
+		cb.Do(succeeding).Get()
+
+		// The circuit is closed
+		assert.Equal(t, CircuitClosed, cb.State())
+		<-allChanges
+		assert.Equal(t, []string{
+			"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed",
+		}, changes)
+	}))
+}
+
+func TestCircuitBreaker_CanceledProbe(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a half-open circuit breaker whose functions run in a busy pool
+		clock := newManualClock()
+		cb := NewCircuitBreaker(CircuitBreakerConfig{OpenTimeout: time.Minute, Clock: clock})
+		cb.Do(failing).Get()
+		clock.Advance(time.Minute)
+		pool := NewPool(1, 10, Block)
+		defer pool.Shutdown()
+		SetDefaultExecutor(pool)
+		defer SetDefaultExecutor(nil)
+		release := make(chan struct{})
+		pool.Do(func() (interface{}, error) {
+			<-release
+			return nil, nil
+		})
+
+		// When the probe is canceled before it starts
+		probe := cb.Do(func() (interface{}, error) {
+			assert.Fail(t, "the function should not be invoked")
+			return nil, nil
+		})
+		assert.NoError(t, probe.Cancel())
+		close(release)
+
+		// Its slot is given back, so another probe can close the circuit
+		assert.Eventually(t, func() bool {
+			_, err := cb.Do(succeeding).Get()
+			return err == nil
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, CircuitClosed, cb.State())
+	}))
+}
+
+func TestCircuitBreaker_IgnoredErrors(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a circuit breaker that only counts some errors as failures
+		notFound := errors.New("not found")
+		cb := NewCircuitBreaker(CircuitBreakerConfig{
+			Clock: newManualClock(),
+			IsFailure: func(err error) bool {
+				return err != notFound
+			},
+		})
+
+		// The rest of errors do not open the circuit
+		_, err := cb.Do(func() (interface{}, error) {
+			return nil, notFound
+		}).Get()
+		assert.Equal(t, notFound, err)
+		assert.Equal(t, CircuitClosed, cb.State())
+
+		// Nor canceled functions
+		started := make(chan struct{})
+		f := cb.DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+			close(started)
+			<-cancelCtx
+			return nil, ErrorCanceled
+		})
+		<-started
+		f.Cancel()
+		assert.Equal(t, CircuitClosed, cb.State())
+	}))
+}
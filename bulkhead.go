@@ -0,0 +1,204 @@
+package manana
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrorBulkheadFull is the error of the futures whose function has been rejected by a fail-fast
+// Bulkhead, because the limit of running functions has been reached.
+var ErrorBulkheadFull = errors.New("the bulkhead is full")
+
+// BulkheadConfig specifies the limits of a Bulkhead.
+type BulkheadConfig struct {
+	// MaxPerKey is the maximum number of functions that can run concurrently for the same key. If
+	// it is zero, the number of functions per key is not limited.
+	MaxPerKey int
+	// MaxTotal is the maximum number of functions that can run concurrently for all the keys. If
+	// it is zero, the total number of functions is not limited.
+	MaxTotal int
+	// FailFast makes the futures of the functions that exceed the limits to immediately fail with
+	// ErrorBulkheadFull. By default, the functions wait until they can run.
+	FailFast bool
+}
+
+// Occupancy reports the number of functions of a Bulkhead that are running or waiting to run.
+type Occupancy struct {
+	Running int
+	Waiting int
+}
+
+// Bulkhead runs functions in background, as Do and DoCtx do, limiting the number of functions
+// that run concurrently for each key (e.g. tenant, host or endpoint) and for all the keys, so a
+// single key can't exhaust the resources of the application.
+//
+// Functions that exceed the limits wait in a FIFO queue until other functions finish, unless the
+// Bulkhead is configured to fail fast. Canceling the Future of a waiting function removes it from
+// the queue, so it never runs.
+type Bulkhead struct {
+	config BulkheadConfig
+	// mutex protects the following fields
+	mutex   sync.Mutex
+	total   Occupancy
+	perKey  map[string]*Occupancy
+	waiting []*bulkheadTask
+}
+
+type bulkheadTask struct {
+	key     string
+	promise Promise
+	fn      func(cancelCtx <-chan struct{}) (interface{}, error)
+}
+
+// NewBulkhead creates a Bulkhead with the given limits.
+func NewBulkhead(config BulkheadConfig) *Bulkhead {
+	return &Bulkhead{config: config, perKey: map[string]*Occupancy{}}
+}
+
+// Do runs a synchronous function in background, as manana.Do does, when the limits for the given
+// key allow it.
+func (b *Bulkhead) Do(key string, syncFunc func() (interface{}, error)) Future {
+	return b.DoCtx(key, func(_ <-chan struct{}) (interface{}, error) {
+		return syncFunc()
+	})
+}
+
+// DoCtx runs a cancelable function in background, as manana.DoCtx does, when the limits for the
+// given key allow it.
+func (b *Bulkhead) DoCtx(key string, asyncFunc func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	task := &bulkheadTask{key: key, promise: NewPromise(), fn: asyncFunc}
+	b.mutex.Lock()
+	occupancy := b.occupancy(key)
+	if b.fits(occupancy) {
+		b.acquire(occupancy)
+		b.mutex.Unlock()
+		b.start(task)
+		return task.promise
+	}
+	if b.config.FailFast {
+		b.release(key)
+		b.mutex.Unlock()
+		task.promise.Fail(ErrorBulkheadFull)
+		return task.promise
+	}
+	b.waiting = append(b.waiting, task)
+	occupancy.Waiting++
+	b.total.Waiting++
+	b.mutex.Unlock()
+	task.promise.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			b.dequeue(task)
+		}
+	})
+	return task.promise
+}
+
+// Occupancy returns the number of functions that are running or waiting for the given key.
+func (b *Bulkhead) Occupancy(key string) Occupancy {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if occupancy, ok := b.perKey[key]; ok {
+		return *occupancy
+	}
+	return Occupancy{}
+}
+
+// Total returns the number of functions that are running or waiting for all the keys.
+func (b *Bulkhead) Total() Occupancy {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.total
+}
+
+// occupancy returns the occupancy entry of a key, creating it if it does not exist. It must be
+// invoked with the mutex locked.
+func (b *Bulkhead) occupancy(key string) *Occupancy {
+	occupancy, ok := b.perKey[key]
+	if !ok {
+		occupancy = &Occupancy{}
+		b.perKey[key] = occupancy
+	}
+	return occupancy
+}
+
+// fits returns true if a new function for the given occupancy does not exceed the limits. It must
+// be invoked with the mutex locked.
+func (b *Bulkhead) fits(occupancy *Occupancy) bool {
+	return (b.config.MaxPerKey <= 0 || occupancy.Running < b.config.MaxPerKey) &&
+		(b.config.MaxTotal <= 0 || b.total.Running < b.config.MaxTotal)
+}
+
+// acquire must be invoked with the mutex locked
+func (b *Bulkhead) acquire(occupancy *Occupancy) {
+	occupancy.Running++
+	b.total.Running++
+}
+
+// release removes the occupancy entry of a key if it is not used anymore. It must be invoked with
+// the mutex locked.
+func (b *Bulkhead) release(key string) {
+	if occupancy := b.perKey[key]; occupancy != nil && *occupancy == (Occupancy{}) {
+		delete(b.perKey, key)
+	}
+}
+
+// start runs the function of a task that has already acquired its slot, and frees the slot when
+// the function returns
+func (b *Bulkhead) start(task *bulkheadTask) {
+	DefaultExecutor().Do(func() (interface{}, error) {
+		defer b.finish(task.key)
+		// the function is not invoked if the task has been canceled
+		if !task.promise.IsCompleted() {
+			value, err := safeCall(func() (interface{}, error) {
+				return task.fn(task.promise.CancelCtx())
+			})
+			complete(task.promise, value, err)
+		}
+		return nil, nil
+	}).OnFail(func(err error) {
+		// the executor rejected the function
+		b.finish(task.key)
+		task.promise.Fail(err)
+	})
+}
+
+// finish frees the slot of a function, and starts the waiting functions that fit in the limits
+func (b *Bulkhead) finish(key string) {
+	b.mutex.Lock()
+	b.perKey[key].Running--
+	b.total.Running--
+	b.release(key)
+	var ready []*bulkheadTask
+	waiting := b.waiting[:0]
+	for _, task := range b.waiting {
+		occupancy := b.perKey[task.key]
+		if b.fits(occupancy) {
+			occupancy.Waiting--
+			b.total.Waiting--
+			b.acquire(occupancy)
+			ready = append(ready, task)
+		} else {
+			waiting = append(waiting, task)
+		}
+	}
+	b.waiting = waiting
+	b.mutex.Unlock()
+	for _, task := range ready {
+		b.start(task)
+	}
+}
+
+// dequeue removes a canceled task from the waiting queue, if it has not been started yet
+func (b *Bulkhead) dequeue(task *bulkheadTask) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for i, t := range b.waiting {
+		if t == task {
+			b.waiting = append(b.waiting[:i], b.waiting[i+1:]...)
+			b.perKey[task.key].Waiting--
+			b.total.Waiting--
+			b.release(task.key)
+			return
+		}
+	}
+}
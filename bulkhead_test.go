@@ -0,0 +1,118 @@
+package manana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blocking returns a function that signals when it starts and returns the value when the release
+// channel is closed
+func blocking(started chan<- string, release <-chan struct{}, value string) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		started <- value
+		<-release
+		return value, nil
+	}
+}
+
+func TestBulkhead_PerKey(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a bulkhead that runs a single function per key
+		b := NewBulkhead(BulkheadConfig{MaxPerKey: 1})
+		started := make(chan string, 10)
+		release := make(chan struct{})
+
+		// When many functions are submitted for a key
+		a1 := b.Do("a", blocking(started, release, "a1"))
+		a2 := b.Do("a", blocking(started, release, "a2"))
+		b1 := b.Do("b", blocking(started, release, "b1"))
+
+		// Only one of them runs, while other keys are not affected
+		assert.ElementsMatch(t, []string{"a1", "b1"}, []string{<-started, <-started})
+		assert.Equal(t, Occupancy{Running: 1, Waiting: 1}, b.Occupancy("a"))
+		assert.Equal(t, Occupancy{Running: 1}, b.Occupancy("b"))
+		assert.Equal(t, Occupancy{Running: 2, Waiting: 1}, b.Total())
+
+		// And the waiting function runs when the running function finishes
+		close(release)
+		_, err := All(a1, a2, b1).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "a2", <-started)
+		assert.Eventually(t, func() bool {
+			return b.Total() == Occupancy{}
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestBulkhead_MaxTotal(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a bulkhead with a global limit
+		b := NewBulkhead(BulkheadConfig{MaxTotal: 2})
+		started := make(chan string, 10)
+		release := make(chan struct{})
+
+		// When more functions than the limit are submitted for different keys
+		f1 := b.Do("a", blocking(started, release, "a"))
+		f2 := b.Do("b", blocking(started, release, "b"))
+		f3 := b.Do("c", blocking(started, release, "c"))
+		<-started
+		<-started
+
+		// The exceeding functions wait
+		assert.Equal(t, Occupancy{Running: 2, Waiting: 1}, b.Total())
+		assert.Equal(t, Occupancy{Waiting: 1}, b.Occupancy("c"))
+
+		close(release)
+		_, err := All(f1, f2, f3).Get()
+		assert.NoError(t, err)
+	})
+}
+
+func TestBulkhead_FailFast(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a fail-fast bulkhead that is full
+		b := NewBulkhead(BulkheadConfig{MaxPerKey: 1, FailFast: true})
+		started := make(chan string, 10)
+		release := make(chan struct{})
+		f1 := b.Do("a", blocking(started, release, "a1"))
+
+		// When a function exceeds the limit
+		_, err := b.Do("a", blocking(started, release, "a2")).Get()
+
+		// It fails immediately
+		assert.Equal(t, ErrorBulkheadFull, err)
+
+		close(release)
+		_, err = f1.Get()
+		assert.NoError(t, err)
+	})
+}
+
+func TestBulkhead_CancelWaiting(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a waiting function
+		b := NewBulkhead(BulkheadConfig{MaxPerKey: 1})
+		started := make(chan string, 10)
+		release := make(chan struct{})
+		f1 := b.Do("a", blocking(started, release, "a1"))
+		f2 := b.Do("a", blocking(started, release, "a2"))
+		<-started
+
+		// When it is canceled
+		assert.NoError(t, f2.Cancel())
+
+		// It leaves the queue and never runs
+		assert.Eventually(t, func() bool {
+			return b.Occupancy("a") == Occupancy{Running: 1}
+		}, time.Second, time.Millisecond)
+		close(release)
+		_, err := f1.Get()
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return b.Total() == Occupancy{}
+		}, time.Second, time.Millisecond)
+		assert.Empty(t, started)
+	})
+}
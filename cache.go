@@ -0,0 +1,199 @@
+package manana
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheConfig specifies the expiration and eviction policies of a Cache. Zero values disable the
+// corresponding feature.
+type CacheConfig struct {
+	// TTL is the time a successfully loaded value is kept. If zero, values do not expire.
+	TTL time.Duration
+	// NegativeTTL is the time a loading error is kept. If zero, errors are not cached, and the
+	// next Get invocation for the key invokes the loader again.
+	NegativeTTL time.Duration
+	// RefreshAhead is the time before the expiration of a value from which a Get invocation
+	// reloads it in background, while the current value keeps being returned.
+	RefreshAhead time.Duration
+	// MaxSize is the maximum number of entries. When it is exceeded, the least recently used entry
+	// is evicted. If zero, the number of entries is not limited.
+	MaxSize int
+	// Clock provides the time to the Cache. Default: SystemClock.
+	Clock Clock
+}
+
+// CacheStats are the statistics of the usage of a Cache.
+type CacheStats struct {
+	// Hits is the number of Get invocations that found an entry for their key, including those
+	// whose value was still being loaded.
+	Hits int64
+	// Misses is the number of Get invocations that invoked the loader.
+	Misses int64
+	// Evictions is the number of entries that were removed to keep the maximum size.
+	Evictions int64
+	// Refreshes is the number of values that were successfully reloaded ahead of their expiration.
+	Refreshes int64
+}
+
+// Cache keeps the futures of values that are asynchronously loaded. Concurrent Get invocations
+// for a key that is being loaded share the same load.
+type Cache struct {
+	config CacheConfig
+	// mutex protects the following fields, and the fields of the entries
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	// lru keeps the entries sorted by their last usage, most recent first
+	lru   *list.List
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key    string
+	future Future
+	// loaded is true when the future has been completed and the expiration has been set
+	loaded bool
+	// expires is the expiration time of the entry, or zero if it never expires
+	expires    time.Time
+	refreshing bool
+}
+
+// NewCache creates an empty Cache.
+func NewCache(config CacheConfig) *Cache {
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+	return &Cache{
+		config:  config,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Get returns a Future that completes with the value cached for the key. If there is no value, or
+// it has expired, the loader function is run in background, as Do does, and its result is cached.
+//
+// Each invocation returns its own Future, so canceling it does not cancel the load for the rest
+// of invocations, nor removes the entry from the Cache.
+func (c *Cache) Get(key string, loader func() (interface{}, error)) Future {
+	c.mutex.Lock()
+	now := c.config.Clock.Now()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if !entry.loaded || entry.expires.IsZero() || now.Before(entry.expires) {
+			c.stats.Hits++
+			c.lru.MoveToFront(element)
+			refresh := c.config.RefreshAhead > 0 && entry.loaded && !entry.expires.IsZero() &&
+				!entry.refreshing && entry.expires.Sub(now) <= c.config.RefreshAhead
+			if refresh {
+				entry.refreshing = true
+			}
+			future := entry.future
+			c.mutex.Unlock()
+			if refresh {
+				c.refresh(entry, loader)
+			}
+			return view(future)
+		}
+		c.remove(element)
+	}
+	c.stats.Misses++
+	promise := NewPromise()
+	entry := &cacheEntry{key: key, future: promise}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.config.MaxSize > 0 && c.lru.Len() > c.config.MaxSize {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+	c.mutex.Unlock()
+
+	Do(loader).OnComplete(func(value interface{}, err error) {
+		// the expiration is set before completing the entry, so the invocations that get the
+		// result never see an entry without expiration
+		c.loaded(entry, err)
+		complete(promise, value, err)
+	})
+	return view(promise)
+}
+
+// Invalidate removes the entry for the key, if any.
+func (c *Cache) Invalidate(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of entries of the Cache, including those that are being loaded and those
+// that have expired but have not been removed yet.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the usage statistics of the Cache.
+func (c *Cache) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// loaded sets the expiration of an entry once its value has been loaded, or removes it if the
+// load failed and errors are not cached
+func (c *Cache) loaded(entry *cacheEntry, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[entry.key]
+	if entry.loaded || !ok || element.Value != entry {
+		return
+	}
+	entry.loaded = true
+	ttl := c.config.TTL
+	if err != nil {
+		if c.config.NegativeTTL <= 0 {
+			c.remove(element)
+			return
+		}
+		ttl = c.config.NegativeTTL
+	}
+	if ttl > 0 {
+		entry.expires = c.config.Clock.Now().Add(ttl)
+	}
+}
+
+// refresh reloads the value of an entry in background, replacing it on success
+func (c *Cache) refresh(entry *cacheEntry, loader func() (interface{}, error)) {
+	Do(loader).OnComplete(func(value interface{}, err error) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		entry.refreshing = false
+		if element, ok := c.entries[entry.key]; err == nil && ok && element.Value == entry {
+			refreshed := NewPromise()
+			refreshed.Success(value)
+			entry.future = refreshed
+			if c.config.TTL > 0 {
+				entry.expires = c.config.Clock.Now().Add(c.config.TTL)
+			}
+			c.stats.Refreshes++
+		}
+	})
+}
+
+// remove must be invoked with the mutex locked
+func (c *Cache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// view returns a Future that completes with the same result as the argument future, and whose
+// cancellation does not affect it
+func view(future Future) Future {
+	p := NewPromise()
+	future.OnComplete(func(value interface{}, err error) {
+		complete(p, value, err)
+	})
+	return p
+}
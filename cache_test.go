@@ -0,0 +1,178 @@
+package manana
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// counter returns a loader that returns the number of times it has been invoked
+func counter(loads *int32) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return atomic.AddInt32(loads, 1), nil
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a cache with a TTL
+		clock := newManualClock()
+		c := NewCache(CacheConfig{TTL: time.Minute, Clock: clock})
+		var loads int32
+
+		// The value is loaded on the first access
+		val, err := c.Get("key", counter(&loads)).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), val)
+
+		// And cached for the next accesses
+		val, err = c.Get("key", counter(&loads)).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), val)
+
+		// Until it expires
+		clock.Advance(time.Minute)
+		val, err = c.Get("key", counter(&loads)).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), val)
+		assert.Equal(t, CacheStats{Hits: 1, Misses: 2}, c.Stats())
+	}))
+}
+
+func TestCache_SharedLoad(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a slow loader
+		c := NewCache(CacheConfig{})
+		var loads int32
+		release := make(chan struct{})
+		loader := func() (interface{}, error) {
+			<-release
+			return atomic.AddInt32(&loads, 1), nil
+		}
+
+		// When the same key is requested concurrently
+		f1 := c.Get("key", loader)
+		f2 := c.Get("key", loader)
+
+		// Canceling one of the requests does not cancel the load
+		assert.NoError(t, f1.Cancel())
+		close(release)
+
+		// And the load is shared
+		val, err := f2.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), val)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	}))
+}
+
+func TestCache_NegativeTTL(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a loader that fails
+		var loads int32
+		loader := func() (interface{}, error) {
+			return nil, fmt.Errorf("failure %d", atomic.AddInt32(&loads, 1))
+		}
+
+		// When errors are not cached
+		c := NewCache(CacheConfig{TTL: time.Hour})
+		_, err := c.Get("key", loader).Get()
+		assert.EqualError(t, err, "failure 1")
+
+		// The loader is invoked again
+		_, err = c.Get("key", loader).Get()
+		assert.EqualError(t, err, "failure 2")
+
+		// When errors are cached
+		clock := newManualClock()
+		c = NewCache(CacheConfig{TTL: time.Hour, NegativeTTL: time.Second, Clock: clock})
+		_, err = c.Get("key", loader).Get()
+		assert.EqualError(t, err, "failure 3")
+
+		// They are returned until the negative TTL expires
+		_, err = c.Get("key", loader).Get()
+		assert.EqualError(t, err, "failure 3")
+		clock.Advance(time.Second)
+		_, err = c.Get("key", loader).Get()
+		assert.EqualError(t, err, "failure 4")
+	}))
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a cache that refreshes the values near their expiration
+		clock := newManualClock()
+		c := NewCache(CacheConfig{TTL: time.Minute, RefreshAhead: 10 * time.Second, Clock: clock})
+		var loads int32
+		_, err := c.Get("key", counter(&loads)).Get()
+		assert.NoError(t, err)
+
+		// When a value is accessed near its expiration
+		clock.Advance(55 * time.Second)
+		val, err := c.Get("key", counter(&loads)).Get()
+
+		// The current value is returned, and the value is reloaded in background
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), val)
+		assert.Eventually(t, func() bool {
+			return c.Stats().Refreshes == 1
+		}, time.Second, time.Millisecond)
+
+		// So the refreshed value is returned after the original expiration
+		clock.Advance(10 * time.Second)
+		val, err = c.Get("key", counter(&loads)).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), val)
+		assert.Equal(t, int64(1), c.Stats().Misses)
+	}))
+}
+
+func TestCache_LRU(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a cache with a maximum size
+		c := NewCache(CacheConfig{MaxSize: 2})
+		load := func(value string) func() (interface{}, error) {
+			return func() (interface{}, error) {
+				return value, nil
+			}
+		}
+		c.Get("a", load("a")).Get()
+		c.Get("b", load("b")).Get()
+
+		// When an entry is used and a new entry exceeds the size
+		c.Get("a", load("a")).Get()
+		c.Get("c", load("c")).Get()
+
+		// The least recently used entry is evicted
+		assert.Equal(t, 2, c.Len())
+		val, _ := c.Get("a", load("reloaded")).Get()
+		assert.Equal(t, "a", val)
+		val, _ = c.Get("b", load("reloaded")).Get()
+		assert.Equal(t, "reloaded", val)
+		assert.Equal(t, int64(2), c.Stats().Evictions)
+	}))
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a cached value
+		c := NewCache(CacheConfig{})
+		var loads int32
+		c.Get("key", counter(&loads)).Get()
+
+		// When it is invalidated, it is loaded again
+		c.Invalidate("key")
+		val, err := c.Get("key", counter(&loads)).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), val)
+
+		_, err = c.Get("other", func() (interface{}, error) {
+			return nil, errors.New("catapun")
+		}).Get()
+		assert.EqualError(t, err, "catapun")
+	}))
+}
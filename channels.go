@@ -0,0 +1,53 @@
+package manana
+
+import "errors"
+
+// ErrorChannelClosed is the error of a Future created with FromChannel whose channel is closed
+// before receiving any value.
+var ErrorChannelClosed = errors.New("the channel has been closed without receiving any value")
+
+func (f *promiseImpl) Done() <-chan struct{} {
+	return f.completed
+}
+
+func (f *promiseImpl) ResultChan() <-chan Result {
+	results := make(chan Result, 1)
+	f.OnComplete(func(value interface{}, err error) {
+		results <- Result{Value: value, Err: err}
+		close(results)
+	})
+	return results
+}
+
+// FromChannel returns a Future that succeeds with the first value received from the argument
+// channel, or fails with ErrorChannelClosed if the channel is closed before receiving any value.
+// Canceling the returned Future stops waiting for the channel.
+func FromChannel(values <-chan interface{}) Future {
+	p := NewPromise().(*promiseImpl)
+	go func() {
+		select {
+		case value, ok := <-values:
+			if ok {
+				p.Success(value)
+			} else {
+				p.Fail(ErrorChannelClosed)
+			}
+		case <-p.completed:
+		}
+	}()
+	return p
+}
+
+// ToChannel returns a channel that receives the success value of the argument Future, and is
+// closed afterwards. If the Future fails or is canceled, the channel is closed without receiving
+// any value.
+func ToChannel(future Future) <-chan interface{} {
+	values := make(chan interface{}, 1)
+	future.OnComplete(func(value interface{}, err error) {
+		if err == nil {
+			values <- value
+		}
+		close(values)
+	})
+	return values
+}
@@ -0,0 +1,114 @@
+package manana
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDone_Select(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that is not completed
+		p := NewPromise()
+
+		// It can be selected together with timers
+		select {
+		case <-p.Done():
+			assert.Fail(t, "the future should not be completed")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		// And when it completes, its Done channel is closed
+		assert.NoError(t, p.Success(1))
+		select {
+		case <-p.Done():
+		case <-time.After(time.Second):
+			assert.Fail(t, "the future should be completed")
+		}
+	}))
+}
+
+func TestResultChan(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given two futures
+		first := after(10*time.Millisecond, "first", nil)
+		second := after(20*time.Millisecond, nil, errors.New("catapun"))
+
+		// The results can be received from their result channels
+		firstResults, secondResults := first.ResultChan(), second.ResultChan()
+		var results []Result
+		for len(results) < 2 {
+			select {
+			case r := <-firstResults:
+				results = append(results, r)
+				firstResults = nil
+			case r := <-secondResults:
+				results = append(results, r)
+				secondResults = nil
+			}
+		}
+		assert.Equal(t, []Result{{Value: "first"}, {Err: errors.New("catapun")}}, results)
+
+		// And the channel is closed after delivering the result
+		r, ok := <-first.ResultChan()
+		assert.True(t, ok)
+		assert.Equal(t, "first", r.Value)
+	}))
+}
+
+func TestFromChannel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a channel wrapped into a future
+		ch := make(chan interface{})
+		f := FromChannel(ch)
+
+		// When a value is sent through the channel
+		ch <- "hello"
+
+		// The future succeeds with it
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", val)
+	}))
+}
+
+func TestFromChannel_Closed(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a channel that is closed without sending values
+		ch := make(chan interface{})
+		f := FromChannel(ch)
+		close(ch)
+
+		// The future fails
+		_, err := f.Get()
+		assert.Equal(t, ErrorChannelClosed, err)
+	}))
+}
+
+func TestFromChannel_Cancel(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a channel wrapped into a future
+		f := FromChannel(make(chan interface{}))
+
+		// When the future is canceled, it stops waiting for the channel
+		assert.NoError(t, f.Cancel())
+	})
+}
+
+func TestToChannel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future converted to a channel
+		ch := ToChannel(after(10*time.Millisecond, "hello", nil))
+
+		// The channel receives the value and is closed
+		assert.Equal(t, "hello", <-ch)
+		_, ok := <-ch
+		assert.False(t, ok)
+
+		// But a failed future closes the channel without values
+		_, ok = <-ToChannel(after(0, nil, errors.New("catapun")))
+		assert.False(t, ok)
+	}))
+}
@@ -0,0 +1,93 @@
+package manana
+
+// Then returns a Future that, when the argument future succeeds, runs the continuation function
+// with its success value. The returned Future completes with the value or the error returned by
+// the continuation function.
+//
+// If the argument future fails, the returned Future fails with the same error without invoking
+// the continuation. If it is canceled, the returned Future is canceled too. Canceling the
+// returned Future also cancels the argument future. If the continuation panics, the returned Future
+// fails with a *PanicError.
+func Then(future Future, continuation func(value interface{}) (interface{}, error)) Future {
+	p := derivedPromise(future)
+	future.OnSuccess(func(value interface{}) {
+		if p.IsCompleted() {
+			return
+		}
+		result, err := safeCall(func() (interface{}, error) {
+			return continuation(value)
+		})
+		complete(p, result, err)
+	})
+	return p
+}
+
+// Map returns a Future that, when the argument future succeeds, succeeds with the value
+// resulting of applying the mapper function to the success value of the argument future.
+// Failures and cancellation are propagated as in the Then function.
+func Map(future Future, mapper func(value interface{}) interface{}) Future {
+	return Then(future, func(value interface{}) (interface{}, error) {
+		return mapper(value), nil
+	})
+}
+
+// FlatMap returns a Future that, when the argument future succeeds, invokes the continuation
+// function with its success value, and completes with the same result as the Future returned by
+// the continuation. This allows chaining asynchronous operations without nesting futures.
+// Failures and cancellation are propagated as in the Then function. Canceling the returned Future
+// also cancels the Future returned by the continuation, if it has been already invoked. If the
+// continuation panics, the returned Future fails with a *PanicError, and if it returns nil, the
+// returned Future fails with ErrorNilFuture.
+func FlatMap(future Future, continuation func(value interface{}) Future) Future {
+	p := derivedPromise(future)
+	future.OnSuccess(func(value interface{}) {
+		if p.IsCompleted() {
+			return
+		}
+		next, err := safeFuture(func() Future {
+			return continuation(value)
+		})
+		if err != nil {
+			p.Fail(err)
+			return
+		}
+		next.OnComplete(func(value interface{}, err error) {
+			complete(p, value, err)
+		})
+		p.OnFail(func(err error) {
+			if err == ErrorCanceled {
+				next.Cancel()
+			}
+		})
+	})
+	return p
+}
+
+// derivedPromise returns a new Promise whose result depends on the source future. The returned
+// Promise fails when the source fails, and cancellation is propagated in both directions. Setting
+// the result of the returned Promise when the source succeeds is left to the invoker.
+func derivedPromise(source Future) Promise {
+	p := NewPromise()
+	source.OnFail(func(err error) {
+		complete(p, nil, err)
+	})
+	p.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			source.Cancel()
+		}
+	})
+	return p
+}
+
+// complete sets the result of a promise from a (value, error) pair, as returned by the functions
+// wrapped by Do. An ErrorCanceled error cancels the promise instead of failing it.
+func complete(p Promise, value interface{}, err error) error {
+	switch err {
+	case nil:
+		return p.Success(value)
+	case ErrorCanceled:
+		return p.Cancel()
+	default:
+		return p.Fail(err)
+	}
+}
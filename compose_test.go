@@ -0,0 +1,199 @@
+package manana
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThen_Success(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that succeeds
+		f := Do(func() (interface{}, error) {
+			return 20, nil
+		})
+
+		// When chaining a continuation function
+		next := Then(f, func(value interface{}) (interface{}, error) {
+			return value.(int) + 1, nil
+		})
+
+		// The returned future succeeds with the result of the continuation
+		val, err := next.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, 21, val)
+	}))
+}
+
+func TestThen_ContinuationError(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that succeeds
+		f := Do(func() (interface{}, error) {
+			return 20, nil
+		})
+
+		// When chaining a continuation function that fails
+		next := Then(f, func(value interface{}) (interface{}, error) {
+			return nil, errors.New("catapun")
+		})
+
+		// The returned future fails with the continuation error
+		_, err := next.Get()
+		assert.EqualError(t, err, "catapun")
+	}))
+}
+
+func TestThen_SourceError(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that fails
+		f := Do(func() (interface{}, error) {
+			return nil, errors.New("catapun")
+		})
+
+		// When chaining some continuations
+		invoked := false
+		next := Map(Then(f, func(value interface{}) (interface{}, error) {
+			invoked = true
+			return value, nil
+		}), func(value interface{}) interface{} {
+			invoked = true
+			return value
+		})
+
+		// The error is propagated through the whole chain
+		_, err := next.Get()
+		assert.EqualError(t, err, "catapun")
+		// without invoking the continuations
+		assert.False(t, invoked)
+	}))
+}
+
+func TestThen_SourceCanceled(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a chain of futures
+		f := NewPromise()
+		next := Map(f, func(value interface{}) interface{} {
+			return value
+		})
+
+		// When the source future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// The chained future is canceled too
+		_, err := next.Get()
+		assert.Equal(t, ErrorCanceled, err)
+		assert.True(t, next.IsCanceled())
+	}))
+}
+
+func TestThen_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a chain of futures
+		f := NewPromise()
+		next := Then(f, func(value interface{}) (interface{}, error) {
+			return value, nil
+		})
+
+		// When the chained future is canceled
+		assert.NoError(t, next.Cancel())
+
+		// The source future is eventually canceled too
+		<-f.CancelCtx()
+		assert.True(t, f.IsCanceled())
+	}))
+}
+
+func TestMap(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pipeline of mapping functions
+		f := Map(Map(Do(func() (interface{}, error) {
+			return 3, nil
+		}), func(value interface{}) interface{} {
+			return value.(int) * 2
+		}), func(value interface{}) interface{} {
+			return value.(int) + 1
+		})
+
+		// The result is computed from top to bottom
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, 7, val)
+	}))
+}
+
+func TestFlatMap(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future
+		f := Do(func() (interface{}, error) {
+			return 3, nil
+		})
+
+		// When composing it with another asynchronous operation
+		next := FlatMap(f, func(value interface{}) Future {
+			return Do(func() (interface{}, error) {
+				return value.(int) * 10, nil
+			})
+		})
+
+		// The returned future is flattened
+		val, err := next.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, 30, val)
+	}))
+}
+
+func TestFlatMap_InnerError(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a composition of futures whose second operation fails
+		next := FlatMap(Do(func() (interface{}, error) {
+			return 3, nil
+		}), func(value interface{}) Future {
+			return Do(func() (interface{}, error) {
+				return nil, errors.New("catapun")
+			})
+		})
+
+		// The returned future fails with the error of the inner future
+		_, err := next.Get()
+		assert.EqualError(t, err, "catapun")
+	}))
+}
+
+func TestFlatMap_NilFuture(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a continuation that returns a nil future
+		next := FlatMap(Do(func() (interface{}, error) {
+			return 3, nil
+		}), func(value interface{}) Future {
+			return nil
+		})
+
+		// The returned future fails
+		_, err := next.Get()
+		assert.Equal(t, ErrorNilFuture, err)
+	}))
+}
+
+func TestFlatMap_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a composition of futures whose inner future is running
+		inner := NewPromise()
+		started := make(chan struct{})
+		next := FlatMap(Do(func() (interface{}, error) {
+			return 3, nil
+		}), func(value interface{}) Future {
+			close(started)
+			return inner
+		})
+		<-started
+
+		// When the composed future is canceled
+		assert.NoError(t, next.Cancel())
+
+		// The inner future is eventually canceled
+		<-inner.CancelCtx()
+		assert.True(t, inner.IsCanceled())
+	}))
+}
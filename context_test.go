@@ -0,0 +1,175 @@
+package manana
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey string
+
+func TestDoContext_Success(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a context with request-scoped values
+		parent := context.WithValue(context.Background(), ctxKey("user"), "mario")
+
+		// When a context-aware function is run asynchronously
+		f := DoContext(parent, func(ctx context.Context) (interface{}, error) {
+			return ctx.Value(ctxKey("user")), nil
+		})
+
+		// The function receives the values of the parent context
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "mario", val)
+	}))
+}
+
+func TestDoContext_ParentCanceled(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a long-running context-aware function
+		parent, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		interrupted := make(chan struct{})
+		f := DoContext(parent, func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			close(interrupted)
+			return nil, ctx.Err()
+		})
+		<-started
+
+		// When the parent context is canceled
+		cancel()
+
+		// The future fails with the error of the context
+		_, err := f.Get()
+		assert.Equal(t, context.Canceled, err)
+		assert.False(t, f.IsCanceled())
+		// And the function is interrupted
+		<-interrupted
+	}))
+}
+
+func TestDoContext_ParentDeadline(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a context with a deadline
+		parent, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		// When a function runs for longer than the deadline
+		f := DoContext(parent, func(ctx context.Context) (interface{}, error) {
+			<-time.After(time.Second)
+			return "too late", nil
+		})
+
+		// The future fails with the error of the context
+		_, err := f.Get()
+		assert.Equal(t, context.DeadlineExceeded, err)
+	}))
+}
+
+func TestDoContext_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a long-running context-aware function
+		started := make(chan struct{})
+		interrupted := make(chan struct{})
+		f := DoContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			close(interrupted)
+			return nil, ctx.Err()
+		})
+		<-started
+
+		// When the future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// The context of the function is done
+		<-interrupted
+		_, err := f.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	}))
+}
+
+func TestNewPromiseWithContext(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise bound to a parent context
+		parent, cancel := context.WithCancel(context.Background())
+		p := NewPromiseWithContext(parent)
+
+		// When the parent context is canceled
+		cancel()
+
+		// The promise fails and the held work is notified to be canceled
+		<-p.CancelCtx()
+		_, err := p.Get()
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, ErrorCompleted, p.Success(1))
+	}))
+}
+
+func TestNewPromiseWithContext_CompletedBefore(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise bound to a parent context
+		parent, cancel := context.WithCancel(context.Background())
+		p := NewPromiseWithContext(parent)
+
+		// That succeeds before the parent context is canceled
+		assert.NoError(t, p.Success(1))
+		cancel()
+
+		// The promise keeps its success status
+		val, err := p.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, val)
+		select {
+		case <-p.CancelCtx():
+			assert.Fail(t, "cancel channel should not have been closed")
+		default:
+		}
+	}))
+}
+
+func TestGetContext(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that takes long to complete
+		p := NewPromise()
+
+		// When waiting for it with a context that times out
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := p.GetContext(ctx)
+
+		// The wait finishes with the context error
+		assert.Equal(t, context.DeadlineExceeded, err)
+		// but the future keeps running
+		assert.False(t, p.IsCompleted())
+
+		// And it can be waited for later
+		assert.NoError(t, p.Success("ok"))
+		val, err := p.GetContext(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", val)
+	}))
+}
+
+func TestFuture_Context(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future
+		p := NewPromiseWithContext(context.WithValue(context.Background(), ctxKey("k"), "v"))
+
+		// Its context carries the parent values and is alive while the future runs
+		ctx := p.Context()
+		assert.Equal(t, "v", ctx.Value(ctxKey("k")))
+		assert.NoError(t, ctx.Err())
+
+		// When the future completes
+		assert.NoError(t, p.Success(1))
+
+		// Its context is done
+		<-ctx.Done()
+	}))
+}
@@ -0,0 +1,162 @@
+package manana
+
+import "sync"
+
+// Dispatcher specifies how the callbacks of a Future are invoked. The callbacks of a Future are
+// passed to its Dispatcher in the order they were registered. Panics in the callbacks are handled
+// as specified by the CallbackPanicPolicy.
+type Dispatcher interface {
+	// Dispatch invokes, or schedules the invocation of, a callback.
+	Dispatch(callback func())
+}
+
+type dispatcherFunc func(callback func())
+
+func (d dispatcherFunc) Dispatch(callback func()) {
+	d(callback)
+}
+
+// Async is the default Dispatcher. It runs each callback in its own goroutine, so the callbacks
+// may run in any order.
+var Async Dispatcher = asyncDispatcher{}
+
+// asyncDispatcher is comparable, unlike dispatcherFunc, so Async can be told apart from other
+// dispatchers
+type asyncDispatcher struct{}
+
+func (asyncDispatcher) Dispatch(callback func()) {
+	dispatch(callback)
+}
+
+// Inline is a Dispatcher that runs the callbacks synchronously, in registration order, in the
+// goroutine that completes the Future. Callbacks registered once the Future is completed run
+// synchronously in the registering goroutine. Inline callbacks should be short and non-blocking,
+// since they delay the goroutine that completes the Future.
+var Inline Dispatcher = dispatcherFunc(runCallback)
+
+// NewSerialDispatcher returns a Dispatcher that runs the callbacks one after another, in the order
+// they are dispatched, in a background goroutine that only lives while there are callbacks to
+// run. A serial Dispatcher can be shared by many futures, to run all their callbacks as in an
+// event loop.
+func NewSerialDispatcher() Dispatcher {
+	return &serialDispatcher{}
+}
+
+type serialDispatcher struct {
+	mutex   sync.Mutex
+	queue   []func()
+	running bool
+}
+
+func (d *serialDispatcher) Dispatch(callback func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.queue = append(d.queue, callback)
+	if !d.running {
+		d.running = true
+		go d.drain()
+	}
+}
+
+func (d *serialDispatcher) drain() {
+	for {
+		d.mutex.Lock()
+		if len(d.queue) == 0 {
+			d.running = false
+			d.mutex.Unlock()
+			return
+		}
+		callback := d.queue[0]
+		d.queue[0] = nil
+		d.queue = d.queue[1:]
+		d.mutex.Unlock()
+		runCallback(callback)
+	}
+}
+
+// ExecutorDispatcher returns a Dispatcher that submits each callback to the passed Executor. The
+// callbacks are submitted in registration order, but the order they run depends on the Executor:
+// e.g. a Pool with a single worker runs them in the submission order. If the Executor rejects a
+// callback, it runs synchronously in the goroutine that dispatches it, as the Inline Dispatcher
+// does, so it is never lost.
+func ExecutorDispatcher(executor Executor) Dispatcher {
+	return dispatcherFunc(func(callback func()) {
+		submitted := executor.Do(func() (interface{}, error) {
+			runCallback(callback)
+			return nil, nil
+		})
+		WithDispatcher(submitted, Inline).OnFail(func(err error) {
+			if err == ErrorRejected {
+				runCallback(callback)
+			}
+		})
+	})
+}
+
+// NewPromiseWithDispatcher creates a new, empty promise whose callbacks are invoked by the passed
+// Dispatcher, unless another Dispatcher is specified on registration (see WithDispatcher).
+func NewPromiseWithDispatcher(dispatcher Dispatcher) Promise {
+	p := NewPromise().(*promiseImpl)
+	p.dispatcher = dispatcher
+	return p
+}
+
+// WithDispatcher returns a view of the argument future whose OnSuccess, OnFail and OnComplete
+// methods register callbacks that are invoked by the passed Dispatcher, keeping the registration
+// order with respect to the other callbacks of the future. E.g.:
+//
+//	manana.WithDispatcher(future, manana.Inline).OnSuccess(updateState)
+//
+// The rest of methods are delegated to the argument future.
+func WithDispatcher(future Future, dispatcher Dispatcher) Future {
+	return dispatchedFuture{Future: future, dispatcher: dispatcher}
+}
+
+type dispatchedFuture struct {
+	Future
+	dispatcher Dispatcher
+}
+
+func (d dispatchedFuture) OnSuccess(callback func(_ interface{})) {
+	d.register(successCallback, func(value interface{}, _ error) {
+		callback(value)
+	})
+}
+
+func (d dispatchedFuture) OnFail(callback func(_ error)) {
+	d.register(failCallback, func(_ interface{}, err error) {
+		callback(err)
+	})
+}
+
+func (d dispatchedFuture) OnComplete(callback func(_ interface{}, _ error)) {
+	d.register(completeCallback, callback)
+}
+
+func (d dispatchedFuture) wrapped() Future {
+	return d.Future
+}
+
+// wrapper is implemented by the types of this package that wrap another Future without redefining
+// its callback registration methods, so the callbacks can be registered in the wrapped Future.
+type wrapper interface {
+	wrapped() Future
+}
+
+// register adds the callback to the underlying promise, unwrapping the futures that wrap it.
+// Futures that are not implemented by this package invoke the callback through their own
+// registration mechanism, so their order and the goroutine they run in depend on it.
+func (d dispatchedFuture) register(kind callbackKind, run func(_ interface{}, _ error)) {
+	switch f := d.Future.(type) {
+	case *promiseImpl:
+		f.register(d.dispatcher, kind, run)
+	case wrapper:
+		dispatchedFuture{Future: f.wrapped(), dispatcher: d.dispatcher}.register(kind, run)
+	default:
+		f.OnComplete(func(value interface{}, err error) {
+			if err == nil && kind != failCallback || err != nil && kind != successCallback {
+				d.dispatcher.Dispatch(func() { run(value, err) })
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package manana
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_Inline(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise whose callbacks are dispatched inline
+		p := NewPromiseWithDispatcher(Inline)
+		var order []string
+		p.OnSuccess(func(_ interface{}) {
+			order = append(order, "success")
+		})
+		p.OnFail(func(_ error) {
+			order = append(order, "fail")
+		})
+		p.OnComplete(func(_ interface{}, _ error) {
+			order = append(order, "complete")
+			// registering a callback from another callback does not deadlock
+			p.OnComplete(func(_ interface{}, _ error) {
+				order = append(order, "nested")
+			})
+		})
+
+		// When the promise completes
+		assert.NoError(t, p.Success(1))
+
+		// The callbacks have run in registration order before Success returns
+		assert.Equal(t, []string{"success", "complete", "nested"}, order)
+
+		// And callbacks registered after the completion run immediately
+		p.OnSuccess(func(_ interface{}) {
+			order = append(order, "late")
+		})
+		assert.Equal(t, []string{"success", "complete", "nested", "late"}, order)
+	}))
+}
+
+func TestDispatcher_Serial(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a set of promises that share a serial dispatcher
+		dispatcher := NewSerialDispatcher()
+		p1 := NewPromiseWithDispatcher(dispatcher)
+		p2 := NewPromiseWithDispatcher(dispatcher)
+		var mutex sync.Mutex
+		var order []int
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			n := i
+			wg.Add(1)
+			p1.OnComplete(func(_ interface{}, _ error) {
+				mutex.Lock()
+				order = append(order, n)
+				mutex.Unlock()
+				wg.Done()
+			})
+		}
+		wg.Add(1)
+		p2.OnFail(func(_ error) {
+			mutex.Lock()
+			order = append(order, 100)
+			mutex.Unlock()
+			wg.Done()
+		})
+
+		// When the promises complete
+		assert.NoError(t, p1.Success(1))
+		assert.NoError(t, p2.Fail(errors.New("catapun")))
+		wg.Wait()
+
+		// All the callbacks have run in the order they were dispatched
+		for i := 0; i <= 100; i++ {
+			assert.Equal(t, i, order[i])
+		}
+	}))
+}
+
+func TestDispatcher_Executor(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise whose callbacks are submitted to a single-worker pool
+		pool := NewPool(1, 100, Block)
+		p := NewPromiseWithDispatcher(ExecutorDispatcher(pool))
+		var order []int
+		for i := 0; i < 10; i++ {
+			n := i
+			p.OnSuccess(func(_ interface{}) {
+				order = append(order, n)
+			})
+		}
+
+		// When the promise completes
+		assert.NoError(t, p.Success(1))
+
+		// The callbacks run in the pool, in registration order
+		pool.Shutdown()
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order)
+	}))
+}
+
+func TestExecutorDispatcher_Rejected(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise whose callbacks are submitted to a pool that has been shut down
+		pool := NewPool(1, 100, Block)
+		pool.Shutdown()
+		p := NewPromiseWithDispatcher(ExecutorDispatcher(pool))
+		var got interface{}
+		p.OnSuccess(func(value interface{}) {
+			got = value
+		})
+
+		// When the promise completes
+		assert.NoError(t, p.Success(1))
+
+		// The rejected callbacks run in the completing goroutine
+		assert.Equal(t, 1, got)
+	}))
+}
+
+func TestWithDispatcher_Wrappers(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pipeline that wraps a promise
+		p := NewPromise()
+		chain := ChainOf(p)
+
+		// When inline callbacks are registered in the pipeline
+		var order []int
+		WithDispatcher(chain, Inline).OnSuccess(func(_ interface{}) {
+			order = append(order, 1)
+		})
+		WithDispatcher(chain, Inline).OnComplete(func(_ interface{}, _ error) {
+			order = append(order, 2)
+		})
+
+		// They run in the goroutine that completes the promise, in registration order
+		assert.NoError(t, p.Success(1))
+		assert.Equal(t, []int{1, 2}, order)
+
+		// And the same happens with the other futures that wrap a promise
+		h := Hedge([]time.Duration{time.Hour}, func(_ <-chan struct{}) (interface{}, error) {
+			return "hedged", nil
+		})
+		_, err := h.Get()
+		assert.NoError(t, err)
+		var got interface{}
+		WithDispatcher(h, Inline).OnSuccess(func(value interface{}) {
+			got = value
+		})
+		assert.Equal(t, "hedged", got)
+	}))
+}
+
+func TestWithDispatcher(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future with the default dispatcher
+		p := NewPromise()
+		async := make(chan struct{})
+		p.OnSuccess(func(_ interface{}) {
+			close(async)
+		})
+
+		// When a callback is registered to be run inline
+		var got interface{}
+		WithDispatcher(p, Inline).OnSuccess(func(value interface{}) {
+			got = value
+		})
+		failed := false
+		WithDispatcher(p, Inline).OnFail(func(_ error) {
+			failed = true
+		})
+
+		// Only that callback runs in the goroutine that completes the future
+		assert.NoError(t, p.Success("hello"))
+		assert.Equal(t, "hello", got)
+		assert.False(t, failed)
+		<-async
+	}))
+}
@@ -7,6 +7,7 @@ import (
 
 	"fmt"
 	"sync"
+	"time"
 
 	"errors"
 
@@ -26,6 +27,9 @@ func main() {
 		{"ubuntu30.iso", "http://releases.ubuntu.com/30.04/ubuntu-30.iso"}, // We expect a 404 error here
 	}
 
+	// Starting at most one download per second, to not overload the remote server
+	limiter := manana.NewRateLimiter(manana.RateLimiterConfig{Limit: 1, Interval: time.Second})
+
 	wg := sync.WaitGroup{}
 	wg.Add(len(downloads))
 	for _, d := range downloads {
@@ -35,7 +39,7 @@ func main() {
 		fmt.Println("Downloading", file, "...")
 
 		// We can handle asynchronously a synchronous function
-		f := manana.Do(func() (interface{}, error) {
+		f := limiter.Do(func() (interface{}, error) {
 			err := Download(file, durl)
 			return nil, err
 		})
@@ -36,15 +36,12 @@ func Asynchronous() {
 		manana.Do(asyncTimeConsumingFunction(100)),
 	)
 
-	d := make(chan manana.Future)
-	f.OnSuccess(func(results interface{}) {
+	val, _ := manana.ChainOf(f).FlatMap(func(results interface{}) manana.Future {
 		a := results.([]interface{})[0].(int)
 		b := results.([]interface{})[1].(int)
 		c := results.([]interface{})[2].(int)
-		d <- manana.Do(asyncTimeConsumingFunction((a + b) / c))
-	})
-
-	val, _ := (<-d).Get()
+		return manana.Do(asyncTimeConsumingFunction((a + b) / c))
+	}).Get()
 	fmt.Printf("The result is %v", val)
 }
 
@@ -0,0 +1,199 @@
+package manana
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrorRejected is the error of the futures whose function has been rejected by an Executor,
+// because its queue is full or because it has been shut down.
+var ErrorRejected = errors.New("the executor rejected the function")
+
+// Executor runs functions in background, returning a Future to get subscribed to their status.
+// The Do, DoCtx, DoContext and DoProgress package functions delegate on a default Executor, which
+// can be replaced with SetDefaultExecutor.
+type Executor interface {
+	// Do runs a synchronous function in background, as manana.Do does.
+	Do(syncFunc func() (interface{}, error)) Future
+	// DoCtx runs a cancelable function in background, as manana.DoCtx does.
+	DoCtx(asyncFunc func(cancelCtx <-chan struct{}) (interface{}, error)) Future
+	// DoContext runs a context-aware function in background, as manana.DoContext does.
+	DoContext(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) Future
+	// DoProgress runs a function that reports its progress in background, as manana.DoProgress
+	// does.
+	DoProgress(fn func(report func(done, total int64)) (interface{}, error)) Future
+}
+
+// submitter implements the Executor interface from a function that schedules the execution of
+// tasks in background, or returns an error if the task can't be scheduled.
+type submitter func(task func()) error
+
+// goroutines is an Executor that runs each function in its own goroutine
+var goroutines = submitter(func(task func()) error {
+	go task()
+	return nil
+})
+
+var defaultExecutor atomic.Value
+
+func init() {
+	defaultExecutor.Store(executorHolder{goroutines})
+}
+
+// executorHolder allows storing different Executor implementations in an atomic.Value
+type executorHolder struct {
+	Executor
+}
+
+// DefaultExecutor returns the Executor used by the Do, DoCtx and DoContext functions. Unless it is
+// replaced, it runs each function in a new goroutine.
+func DefaultExecutor() Executor {
+	return defaultExecutor.Load().(executorHolder).Executor
+}
+
+// SetDefaultExecutor replaces the Executor used by the Do, DoCtx and DoContext functions, e.g. with
+// a bounded Pool. Passing nil restores the default Executor, which runs each function in a new
+// goroutine.
+func SetDefaultExecutor(executor Executor) {
+	if executor == nil {
+		executor = goroutines
+	}
+	defaultExecutor.Store(executorHolder{executor})
+}
+
+func (s submitter) Do(syncFunc func() (interface{}, error)) Future {
+	return s.run(NewPromise().(*promiseImpl), syncFunc)
+}
+
+func (s submitter) DoCtx(asyncFunc func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	p := NewPromise().(*promiseImpl)
+	return s.run(p, func() (interface{}, error) {
+		return asyncFunc(p.canceled)
+	})
+}
+
+func (s submitter) DoContext(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) Future {
+	p := NewPromiseWithContext(ctx).(*promiseImpl)
+	return s.run(p, func() (interface{}, error) {
+		return fn(p.context)
+	})
+}
+
+func (s submitter) DoProgress(fn func(report func(done, total int64)) (interface{}, error)) Future {
+	p := NewPromise().(*promiseImpl)
+	return s.run(p, func() (interface{}, error) {
+		return fn(func(done, total int64) {
+			p.ReportProgress(done, total)
+		})
+	})
+}
+
+// run submits a task that completes the promise with the result of the passed function. If the
+// promise is already completed (e.g. canceled) when the task starts, the function is not invoked.
+// If the function panics, the promise fails with a *PanicError.
+func (s submitter) run(p *promiseImpl, fn func() (interface{}, error)) Future {
+	err := s(func() {
+		if p.IsCompleted() {
+			return
+		}
+		val, err := safeCall(fn)
+		if err != nil {
+			p.Fail(err)
+		} else {
+			p.Success(val)
+		}
+	})
+	if err != nil {
+		p.Fail(err)
+	}
+	return p
+}
+
+// QueuePolicy specifies the behavior of a Pool when a function is submitted and its queue is full.
+type QueuePolicy int
+
+const (
+	// Block makes the submitter goroutine wait until there is room in the queue.
+	Block QueuePolicy = iota
+	// Reject makes the submission return a Future that fails with ErrorRejected.
+	Reject
+	// CallerRuns makes the submitted function to run synchronously in the submitter goroutine.
+	CallerRuns
+)
+
+// Pool is an Executor that runs the submitted functions in a fixed number of worker goroutines.
+// Functions that can't be immediately run are kept in a queue of limited capacity. When the queue
+// is full, the Pool behaves as specified by its QueuePolicy.
+type Pool struct {
+	submitter
+	policy QueuePolicy
+	tasks  chan func()
+	// mutex prevents submitting tasks while the pool is being shut down
+	mutex   sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// NewPool creates a Pool with the given number of worker goroutines and queue capacity. If the
+// number of workers is not positive, the Pool has a single worker.
+func NewPool(workers, queueCapacity int, policy QueuePolicy) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	p := &Pool{
+		policy: policy,
+		tasks:  make(chan func(), queueCapacity),
+	}
+	p.submitter = p.submit
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.workers.Done()
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+func (p *Pool) submit(task func()) error {
+	p.mutex.RLock()
+	if p.closed {
+		p.mutex.RUnlock()
+		return ErrorRejected
+	}
+	select {
+	case p.tasks <- task:
+		p.mutex.RUnlock()
+		return nil
+	default:
+	}
+	switch p.policy {
+	case Reject:
+		p.mutex.RUnlock()
+		return ErrorRejected
+	case CallerRuns:
+		// the task runs without the lock, as it could wait for a Shutdown invoked from other goroutine
+		p.mutex.RUnlock()
+		task()
+	default:
+		p.tasks <- task
+		p.mutex.RUnlock()
+	}
+	return nil
+}
+
+// Shutdown stops accepting new functions and waits until the functions that are running or queued
+// finish. Functions submitted after the shutdown fail with ErrorRejected.
+func (p *Pool) Shutdown() {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mutex.Unlock()
+	p.workers.Wait()
+}
@@ -0,0 +1,228 @@
+package manana
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_BoundedConcurrency(t *testing.T) {
+	assert.NoError(t, eventually(5*time.Second, func() {
+		// Given a pool of 3 workers
+		pool := NewPool(3, 100, Block)
+		defer pool.Shutdown()
+
+		// When many functions are submitted
+		var running, maxRunning int32
+		futures := make([]Future, 50)
+		for i := range futures {
+			futures[i] = pool.Do(func() (interface{}, error) {
+				now := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if now <= max || atomic.CompareAndSwapInt32(&maxRunning, max, now) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return 1, nil
+			})
+		}
+
+		// All of them complete
+		_, err := All(futures...).Get()
+		assert.NoError(t, err)
+		// But no more than 3 run at the same time
+		assert.True(t, atomic.LoadInt32(&maxRunning) <= 3)
+	}))
+}
+
+func TestPool_Reject(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pool with a single busy worker and a queue of capacity 1
+		pool := NewPool(1, 1, Reject)
+		defer pool.Shutdown()
+		release := make(chan struct{})
+		started := make(chan struct{})
+		busy := pool.Do(func() (interface{}, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+		<-started
+		queued := pool.Do(func() (interface{}, error) {
+			return "queued", nil
+		})
+
+		// When the queue is full
+		rejected := pool.Do(func() (interface{}, error) {
+			assert.Fail(t, "the function should not be invoked")
+			return nil, nil
+		})
+		// The submission is rejected
+		_, err := rejected.Get()
+		assert.Equal(t, ErrorRejected, err)
+
+		// And the rest of functions run normally
+		close(release)
+		_, err = busy.Get()
+		assert.NoError(t, err)
+		val, err := queued.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "queued", val)
+	}))
+}
+
+func TestPool_CallerRuns(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pool with a single busy worker and a full queue
+		pool := NewPool(1, 1, CallerRuns)
+		defer pool.Shutdown()
+		release := make(chan struct{})
+		started := make(chan struct{})
+		pool.Do(func() (interface{}, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+		<-started
+		pool.Do(func() (interface{}, error) { return nil, nil })
+		defer close(release)
+
+		// When a new function is submitted
+		ran := false
+		f := pool.Do(func() (interface{}, error) {
+			ran = true
+			return "caller", nil
+		})
+
+		// It is executed by the submitter goroutine
+		assert.True(t, ran)
+		assert.True(t, f.IsCompleted())
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "caller", val)
+	}))
+}
+
+func TestPool_CallerRunsShutdown(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pool with a single busy worker and a full queue
+		pool := NewPool(1, 1, CallerRuns)
+		release := make(chan struct{})
+		started := make(chan struct{})
+		pool.Do(func() (interface{}, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+		<-started
+		queued := pool.Do(func() (interface{}, error) { return "queued", nil })
+
+		// When a function that runs in the submitter goroutine shuts down the pool
+		f := pool.Do(func() (interface{}, error) {
+			close(release)
+			pool.Shutdown()
+			return "caller", nil
+		})
+
+		// The shutdown does not wait for the submission to finish
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "caller", val)
+		assert.True(t, queued.IsCompleted())
+	}))
+}
+
+func TestPool_NoWorkers(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pool created with no workers
+		pool := NewPool(0, 0, Block)
+		defer pool.Shutdown()
+
+		// The functions still run
+		val, err := pool.Do(func() (interface{}, error) { return "done", nil }).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "done", val)
+	}))
+}
+
+func TestPool_CanceledWhileQueued(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pool with a single busy worker
+		pool := NewPool(1, 10, Block)
+		release := make(chan struct{})
+		pool.Do(func() (interface{}, error) {
+			<-release
+			return nil, nil
+		})
+
+		// When a queued function is canceled
+		f := pool.DoCtx(func(_ <-chan struct{}) (interface{}, error) {
+			assert.Fail(t, "the function should not be invoked")
+			return nil, nil
+		})
+		assert.NoError(t, f.Cancel())
+
+		// It never runs
+		close(release)
+		pool.Shutdown()
+		_, err := f.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	}))
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pool with some queued functions
+		pool := NewPool(1, 10, Block)
+		var executed int32
+		for i := 0; i < 5; i++ {
+			pool.DoContext(context.Background(), func(_ context.Context) (interface{}, error) {
+				atomic.AddInt32(&executed, 1)
+				return nil, nil
+			})
+		}
+
+		// When the pool is shut down
+		pool.Shutdown()
+
+		// The queued functions are executed
+		assert.EqualValues(t, 5, atomic.LoadInt32(&executed))
+
+		// And new submissions are rejected
+		_, err := pool.Do(func() (interface{}, error) { return nil, nil }).Get()
+		assert.Equal(t, ErrorRejected, err)
+	}))
+}
+
+func TestSetDefaultExecutor(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pool set as default executor
+		pool := NewPool(2, 10, Block)
+		SetDefaultExecutor(pool)
+		defer SetDefaultExecutor(nil)
+		assert.Equal(t, pool, DefaultExecutor())
+
+		// When functions are run with the package functions
+		wg := sync.WaitGroup{}
+		wg.Add(3)
+		Do(func() (interface{}, error) { wg.Done(); return nil, nil })
+		DoCtx(func(_ <-chan struct{}) (interface{}, error) { wg.Done(); return nil, nil })
+		DoContext(context.Background(), func(_ context.Context) (interface{}, error) {
+			wg.Done()
+			return nil, nil
+		})
+
+		// They are run by the pool
+		wg.Wait()
+		pool.Shutdown()
+		_, err := Do(func() (interface{}, error) { return nil, nil }).Get()
+		assert.Equal(t, ErrorRejected, err)
+	}))
+}
@@ -0,0 +1,96 @@
+package manana
+
+import "time"
+
+// Chain wraps a Future to compose pipelines by concatenating method invocations, e.g.:
+//
+//	val, err := manana.Start(fetchUser).
+//		Then(loadProfile).
+//		Recover(defaultProfile).
+//		Timeout(time.Second).
+//		WhenSuccess(render).
+//		Get()
+//
+// Each composition method returns a new Chain that wraps the Future returned by the homonym
+// function of this package, so failures and cancellation are propagated as documented there.
+// Chain implements the Future interface through the embedded Future, so it can be passed to any
+// function that accepts futures.
+type Chain struct {
+	Future
+}
+
+func (c Chain) wrapped() Future {
+	return c.Future
+}
+
+// Start runs a synchronous function in background, as Do does, and returns a Chain to compose
+// the following steps of the pipeline.
+func Start(syncFunc func() (interface{}, error)) Chain {
+	return Chain{Do(syncFunc)}
+}
+
+// ChainOf returns a Chain to compose the following steps of the pipeline from an existing Future.
+func ChainOf(future Future) Chain {
+	return Chain{future}
+}
+
+// Then returns a Chain that wraps the result of the Then function.
+func (c Chain) Then(continuation func(value interface{}) (interface{}, error)) Chain {
+	return Chain{Then(c.Future, continuation)}
+}
+
+// Map returns a Chain that wraps the result of the Map function.
+func (c Chain) Map(mapper func(value interface{}) interface{}) Chain {
+	return Chain{Map(c.Future, mapper)}
+}
+
+// FlatMap returns a Chain that wraps the result of the FlatMap function.
+func (c Chain) FlatMap(continuation func(value interface{}) Future) Chain {
+	return Chain{FlatMap(c.Future, continuation)}
+}
+
+// Recover returns a Chain that wraps the result of the Recover function.
+func (c Chain) Recover(recovery func(err error) (interface{}, error), options ...RecoverOption) Chain {
+	return Chain{Recover(c.Future, recovery, options...)}
+}
+
+// RecoverWith returns a Chain that wraps the result of the RecoverWith function.
+func (c Chain) RecoverWith(recovery func(err error) Future, options ...RecoverOption) Chain {
+	return Chain{RecoverWith(c.Future, recovery, options...)}
+}
+
+// MapError returns a Chain that wraps the result of the MapError function.
+func (c Chain) MapError(mapper func(err error) error, options ...RecoverOption) Chain {
+	return Chain{MapError(c.Future, mapper, options...)}
+}
+
+// Timeout returns a Chain that wraps the result of the WithTimeout function.
+func (c Chain) Timeout(timeout time.Duration, options ...TimeoutOption) Chain {
+	return Chain{WithTimeout(c.Future, timeout, options...)}
+}
+
+// Deadline returns a Chain that wraps the result of the WithDeadline function.
+func (c Chain) Deadline(deadline time.Time, options ...TimeoutOption) Chain {
+	return Chain{WithDeadline(c.Future, deadline, options...)}
+}
+
+// WhenSuccess adds a callback to the wrapped Future, as Future.OnSuccess does, and returns the
+// same Chain.
+func (c Chain) WhenSuccess(callback func(_ interface{})) Chain {
+	c.Future.OnSuccess(callback)
+	return c
+}
+
+// WhenFail adds a callback to the wrapped Future, as Future.OnFail does, and returns the same
+// Chain.
+func (c Chain) WhenFail(callback func(_ error)) Chain {
+	c.Future.OnFail(callback)
+	return c
+}
+
+// WhenComplete adds a callback to the wrapped Future, as Future.OnComplete does, and returns the
+// same Chain.
+func (c Chain) WhenComplete(callback func(_ interface{}, _ error)) Chain {
+	c.Future.OnComplete(callback)
+	return c
+}
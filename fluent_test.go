@@ -0,0 +1,79 @@
+package manana
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pipeline composed with the fluent API
+		succeeded := make(chan interface{}, 1)
+		val, err := Start(func() (interface{}, error) {
+			return 2, nil
+		}).Then(func(value interface{}) (interface{}, error) {
+			return value.(int) * 3, nil
+		}).Map(func(value interface{}) interface{} {
+			return value.(int) + 1
+		}).FlatMap(func(value interface{}) Future {
+			return after(time.Millisecond, value.(int)*2, nil)
+		}).Timeout(time.Second).WhenSuccess(func(value interface{}) {
+			succeeded <- value
+		}).Get()
+
+		// Each step is run with the result of the previous one
+		assert.NoError(t, err)
+		assert.Equal(t, 14, val)
+		assert.Equal(t, 14, <-succeeded)
+	}))
+}
+
+func TestChain_Recover(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pipeline whose first step fails
+		continued := false
+		failed := make(chan error, 1)
+		val, err := ChainOf(after(0, nil, errors.New("catapun"))).
+			Then(func(value interface{}) (interface{}, error) {
+				continued = true
+				return value, nil
+			}).
+			WhenFail(func(err error) {
+				failed <- err
+			}).
+			Recover(func(err error) (interface{}, error) {
+				return "recovered: " + err.Error(), nil
+			}).
+			Get()
+
+		// The error skips the continuation and is recovered
+		assert.NoError(t, err)
+		assert.Equal(t, "recovered: catapun", val)
+		assert.False(t, continued)
+		assert.EqualError(t, <-failed, "catapun")
+	}))
+}
+
+func TestChain_Timeout(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pipeline with a slow step
+		source := NewPromise()
+		chain := ChainOf(source).
+			Map(func(value interface{}) interface{} {
+				return value
+			}).
+			Timeout(10 * time.Millisecond)
+
+		// The pipeline times out and the slow step is canceled
+		_, err := chain.Get()
+		assert.Equal(t, ErrorTimeout, err)
+		<-source.CancelCtx()
+
+		// And the chain can be passed to the functions that accept futures
+		_, err = All(chain).Get()
+		assert.Equal(t, ErrorTimeout, err)
+	}))
+}
@@ -1,10 +1,22 @@
 // Package manana provides wrapper functionalities to work with futures and promises, and tools to
 // easy porting your old synchronous code to asynchronous.
+//
+// Futures returned by the functions that compose other futures (All, Any, Then, Recover...) form
+// a tree whose children are the composed futures. Cancellation cascades down the tree: canceling
+// a composite future cancels the children futures that are still running. Composite futures whose
+// result does not depend anymore on the pending children (e.g. All, after a failure) also cancel
+// them. Each function documents how the failure or cancellation of a child is reported back to the
+// composite future.
+//
+// By default, each callback of a Future runs in its own goroutine, so callbacks may run in any
+// order. A Dispatcher can be set to run them inline, serialized in registration order, or in an
+// Executor (see NewPromiseWithDispatcher and WithDispatcher).
 package manana
 
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 )
 
@@ -17,6 +29,9 @@ var ErrorCompleted = errors.New("this future is already completed")
 // ErrorTimeout is an error returned when a timeout has been reached
 var ErrorTimeout = errors.New("this operation has timed out")
 
+// ErrorNilFuture is an error returned when a function that must return a Future returns nil
+var ErrorNilFuture = errors.New("the function returned a nil future")
+
 // Future holds the results of an operation that runs asynchronously, in background.
 type Future interface {
 	// OnSuccess adds a callback to be run when the Future ends with a Success status. The callback
@@ -43,6 +58,8 @@ type Future interface {
 	// specified  timeout is triggered. It may return the value resulting from the successful
 	// execution of the Future (first value), or the error resulting from the failed operation. The
 	// second error may also (or ErrorCanceled, second value).
+	// The Future is not canceled when the timeout is triggered. To bound the duration of the
+	// operation held by the Future, use the WithTimeout function.
 	Eventually(timeout time.Duration) (interface{}, error)
 
 	// Cancel cancels the future. Canceling a future does not guarantee the goroutine it holds
@@ -56,6 +73,34 @@ type Future interface {
 	// IsCanceled returns true if the future has been canceled, even if the held goroutine is still
 	// being executed.
 	IsCanceled() bool
+
+	// GetContext makes the invoker goroutine to wait until the Future completes, or until the
+	// passed context is done. In the latter case, it returns the error of the context, and the
+	// Future keeps running.
+	GetContext(ctx context.Context) (interface{}, error)
+
+	// Context returns a context that is done as soon as the Future completes, whatever its status
+	// is, or when the parent context of the Future is done. It carries the values of the parent
+	// context, so it can be passed to downstream calls to bound them to the lifetime of the Future.
+	Context() context.Context
+
+	// Progress returns the latest progress reported by the operation held in the Future.
+	Progress() Progress
+
+	// OnProgress adds a callback to be run each time the operation held in the Future reports
+	// its progress, while the Future has not completed. The callback is not invoked more than once
+	// each throttle interval: intermediate progress reports are discarded, but the latest report
+	// is eventually delivered. The reports are delivered to each callback in order, through the
+	// Dispatcher of the Future.
+	OnProgress(callback func(_ Progress), throttle time.Duration)
+
+	// Done returns a channel that is closed when the Future completes, whatever its status is. It
+	// allows waiting for the Future in select statements.
+	Done() <-chan struct{}
+
+	// ResultChan returns a channel that receives the Result of the Future when it completes, and
+	// is closed afterwards. Each invocation returns a new channel.
+	ResultChan() <-chan Result
 }
 
 // Promise is a Future whose Success/Fail status can be set.
@@ -68,31 +113,94 @@ type Promise interface {
 	// CancelCtx returns a channel that is closed when the work held in this Promise has to be
 	// canceled.
 	CancelCtx() <-chan struct{}
+	// ReportProgress updates the progress of the work held in this Promise, specifying the done
+	// and the total units of work (e.g. bytes). It returns an error if the Promise is completed.
+	ReportProgress(done, total int64) error
 }
 
+// promiseState is the status of a promise. A pending promise can transition exactly once to any
+// of the other states, which are final.
+type promiseState int
+
+const (
+	pending promiseState = iota
+	succeeded
+	failed
+	canceled
+)
+
 type promiseImpl struct {
-	completed   chan interface{}
-	context     context.Context
-	cancel      context.CancelFunc
-	successCBs  []func(_ interface{})
-	errorCBs    []func(_ error)
-	completeCBs []func(_ interface{}, _ error)
-	value       interface{} // Todo: use atomic
-	err         error       // todo: use atomic
+	// mutex protects the state, value, err and callback fields
+	mutex      sync.Mutex
+	state      promiseState
+	completed  chan struct{} // closed on the transition from pending to any other state
+	canceled   chan struct{} // closed when the held work has to be canceled
+	context    context.Context
+	cancel     context.CancelFunc
+	stopParent func() bool // stops watching the parent context
+	dispatcher Dispatcher
+	// callbacks are kept in registration order, and are dispatched in that order
+	callbacks []callback
+	// drained is true once all the callbacks registered before the completion have been
+	// dispatched, so new callbacks can be dispatched as soon as they are registered
+	drained     bool
+	value       interface{}
+	err         error
+	progress    Progress
+	progressCBs []*progressListener
+}
+
+// callbackKind specifies the final states whose transition invokes a callback
+type callbackKind int
+
+const (
+	successCallback callbackKind = iota
+	failCallback
+	completeCallback
+)
+
+func (k callbackKind) accepts(state promiseState) bool {
+	switch k {
+	case successCallback:
+		return state == succeeded
+	case failCallback:
+		return state == failed || state == canceled
+	default:
+		return true
+	}
+}
+
+type callback struct {
+	kind       callbackKind
+	run        func(value interface{}, err error)
+	dispatcher Dispatcher
 }
 
 // NewPromise creates a new, empty promise. This function is useful if you want to directly manage
 // the status of a Promise from your code. To transparently wrap synchronous code into an
 // asynchronous promise, you may use manana.Do and manana.DoCtx functions.
 func NewPromise() Promise {
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	return NewPromiseWithContext(context.Background())
+}
+
+// NewPromiseWithContext creates a new, empty promise whose lifetime is bound to the passed parent
+// context. If the parent context is done before the promise completes, the promise fails with
+// the error of the parent context, and its CancelCtx channel is closed.
+func NewPromiseWithContext(parent context.Context) Promise {
+	ctx, cancelFunc := context.WithCancel(parent)
 	p := &promiseImpl{
-		completed:   make(chan interface{}),
-		context:     ctx,
-		cancel:      cancelFunc,
-		successCBs:  make([]func(_ interface{}), 0),
-		errorCBs:    make([]func(_ error), 0),
-		completeCBs: make([]func(_ interface{}, _ error), 0),
+		completed:  make(chan struct{}),
+		canceled:   make(chan struct{}),
+		context:    ctx,
+		cancel:     cancelFunc,
+		dispatcher: Async,
+	}
+	if parent.Done() != nil {
+		p.mutex.Lock()
+		p.stopParent = context.AfterFunc(parent, func() {
+			p.complete(failed, nil, parent.Err(), true)
+		})
+		p.mutex.Unlock()
 	}
 	return p
 }
@@ -102,169 +210,169 @@ func NewPromise() Promise {
 //
 // If the wrapped function returns any value as first return value, the future succeeds.
 // If the wrapped function returns an error as second return value, the future fails with the
-// given error.
+// given error. If the wrapped function panics, the future fails with a *PanicError.
+//
+// The function is run by the default Executor (see SetDefaultExecutor).
 func Do(syncFunc func() (interface{}, error)) Future {
-	p := NewPromise().(*promiseImpl)
-	go func() {
-		valCh := make(chan interface{})
-		errCh := make(chan error)
-		go func() {
-			val, err := syncFunc()
-			if err != nil {
-				errCh <- err
-			} else {
-				valCh <- val
-			}
-		}()
-		select {
-		case err := <-errCh:
-			p.Fail(err)
-		case val := <-valCh:
-			p.Success(val)
-		case <-p.context.Done():
-			// do nothing
-		}
-	}()
-	return p
+	return DefaultExecutor().Do(syncFunc)
 }
 
+// DoCtx wraps a cancelable function into an asynchronous envelope, as Do does. The wrapped function
+// receives a channel that is closed when the returned Future is canceled, so it can interrupt its
+// work.
+//
+// The function is run by the default Executor (see SetDefaultExecutor).
 func DoCtx(asyncFunc func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
-	p := NewPromise().(*promiseImpl)
-	go func() {
-		valCh := make(chan interface{})
-		errCh := make(chan error)
-		go func() {
-			val, err := asyncFunc(p.context.Done())
-			if err != nil {
-				errCh <- err
-			} else {
-				valCh <- val
-			}
-		}()
-		select {
-		case err := <-errCh:
-			p.Fail(err)
-		case val := <-valCh:
-			p.Success(val)
-		case <-p.context.Done():
-			// do nothing
-		}
-	}()
-	return p
+	return DefaultExecutor().DoCtx(asyncFunc)
+}
+
+// DoContext wraps a context-aware function into an asynchronous envelope. The function is run in
+// background and receives the context of the returned Future, which is derived from the passed
+// parent context and is done when the Future is canceled or the parent context is done.
+//
+// If the parent context is done before the function returns, the Future fails with the error of
+// the parent context.
+//
+// The function is run by the default Executor (see SetDefaultExecutor).
+func DoContext(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) Future {
+	return DefaultExecutor().DoContext(ctx, fn)
 }
 
 // OnSuccess invokes the statusReceiver function as soon as the future is successfully completed
 func (f *promiseImpl) OnSuccess(callback func(_ interface{})) {
-	if !f.IsCanceled() {
-		if f.IsCompleted() {
-			go callback(f.value)
-			return
-		}
-		f.successCBs = append(f.successCBs, callback)
-	}
+	f.register(nil, successCallback, func(value interface{}, _ error) {
+		callback(value)
+	})
 }
 
 func (f *promiseImpl) OnFail(callback func(_ error)) {
-	if f.IsCompleted() {
-		go callback(f.err)
-		return
-	}
-	f.errorCBs = append(f.errorCBs, callback)
+	f.register(nil, failCallback, func(_ interface{}, err error) {
+		callback(err)
+	})
 }
 
 func (f *promiseImpl) OnComplete(callback func(_ interface{}, _ error)) {
-	if !f.IsCanceled() {
-		if f.IsCompleted() {
-			go callback(f.value, f.err)
-			return
-		}
-		f.completeCBs = append(f.completeCBs, callback)
-	}
+	f.register(nil, completeCallback, callback)
 }
 
-func (f *promiseImpl) Success(value interface{}) error {
-	if f.IsCanceled() {
-		return ErrorCanceled
-	}
-	if f.IsCompleted() {
-		return ErrorCompleted
+// register adds a callback to be dispatched when the promise completes in any of the states
+// accepted by its kind. If the dispatcher is nil, the dispatcher of the promise is used. If the
+// promise is already completed, the callback is dispatched immediately, after the callbacks that
+// were registered before.
+func (f *promiseImpl) register(dispatcher Dispatcher, kind callbackKind, run func(_ interface{}, _ error)) {
+	if dispatcher == nil {
+		dispatcher = f.dispatcher
 	}
-	f.value = value
-	close(f.completed)
-	for _, rCallback := range f.successCBs {
-		go rCallback(f.value)
+	f.mutex.Lock()
+	if !f.drained {
+		f.callbacks = append(f.callbacks, callback{kind: kind, run: run, dispatcher: dispatcher})
+		f.mutex.Unlock()
+		return
 	}
-	for _, rCallback := range f.completeCBs {
-		go rCallback(f.value, nil)
+	state, value, err := f.state, f.value, f.err
+	f.mutex.Unlock()
+	if kind.accepts(state) {
+		dispatcher.Dispatch(func() { run(value, err) })
 	}
-	// callbacks arrays are not needed anymore. Removing
-	f.successCBs = nil
-	f.completeCBs = nil
-	f.errorCBs = nil
-	return nil
+}
+
+func (f *promiseImpl) Success(value interface{}) error {
+	return f.complete(succeeded, value, nil, false)
 }
 
 func (f *promiseImpl) Fail(err error) error {
-	if f.IsCanceled() {
+	return f.complete(failed, nil, err, false)
+}
+
+// complete performs the only allowed transition of the promise, from pending to the given final
+// state, and dispatches the callbacks that were subscribed to it, in registration order.
+// Callbacks registered concurrently are either stored and dispatched by this function, or
+// dispatched immediately by the registering goroutine, so none is lost.
+// If cancelWork is true, the CancelCtx channel is closed.
+func (f *promiseImpl) complete(state promiseState, value interface{}, err error, cancelWork bool) error {
+	f.mutex.Lock()
+	switch f.state {
+	case canceled:
+		f.mutex.Unlock()
 		return ErrorCanceled
-	}
-	if f.IsCompleted() {
+	case succeeded, failed:
+		f.mutex.Unlock()
 		return ErrorCompleted
 	}
+	f.state = state
+	f.value = value
 	f.err = err
+	// progress callbacks are not needed anymore. Removing
+	listeners := f.progressCBs
+	f.progressCBs = nil
+	if cancelWork {
+		close(f.canceled)
+	}
 	close(f.completed)
-	for _, rCallback := range f.errorCBs {
-		go rCallback(f.err)
+	stopParent := f.stopParent
+	f.mutex.Unlock()
+
+	// no progress is delivered after the completion callbacks are dispatched
+	for _, l := range listeners {
+		l.stop()
 	}
-	for _, rCallback := range f.completeCBs {
-		go rCallback(nil, f.err)
+	if stopParent != nil {
+		stopParent()
+	}
+	f.cancel()
+	// callbacks registered while dispatching are appended to the list, and dispatched in the next
+	// iteration, so the registration order is preserved
+	for {
+		f.mutex.Lock()
+		callbacks := f.callbacks
+		f.callbacks = nil
+		if len(callbacks) == 0 {
+			f.drained = true
+			f.mutex.Unlock()
+			return nil
+		}
+		f.mutex.Unlock()
+		for _, cb := range callbacks {
+			if cb.kind.accepts(state) {
+				run := cb.run
+				cb.dispatcher.Dispatch(func() { run(value, err) })
+			}
+		}
 	}
-	// callbacks arrays are not needed anymore. Removing
-	f.successCBs = nil
-	f.completeCBs = nil
-	f.errorCBs = nil
-	return nil
 }
 
 // Get should coexist and close onsuccess
 func (f *promiseImpl) Get() (interface{}, error) {
 	// Wait for completion
+	<-f.completed
+	return f.value, f.err
+}
+
+func (f *promiseImpl) GetContext(ctx context.Context) (interface{}, error) {
 	select {
 	case <-f.completed:
 		return f.value, f.err
-	case <-f.context.Done():
-		return nil, ErrorCanceled
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
-func (f *promiseImpl) Eventually(timeout time.Duration) (interface{}, error) {
-	if f.IsCanceled() {
-		return nil, ErrorCanceled
-	}
-	gotValues := make(chan interface{})
-	defer close(gotValues)
-	var val interface{}
-	var err error
-	go func() {
-		val, err = f.Get()
-		gotValues <- struct{}{}
-	}()
+func (f *promiseImpl) Context() context.Context {
+	return f.context
+}
 
+func (f *promiseImpl) Eventually(timeout time.Duration) (interface{}, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 	select {
-	case <-gotValues:
-		return val, err
-	case <-f.context.Done():
-		return nil, ErrorCanceled
-	case <-time.After(timeout):
-		// todo: should we cancel?
+	case <-f.completed:
+		return f.value, f.err
+	case <-timer.C:
 		return nil, ErrorTimeout
 	}
 }
 
 func (f *promiseImpl) IsCompleted() bool {
-	if f.IsCanceled() {
-		return true
-	}
 	select {
 	case <-f.completed:
 		return true
@@ -274,25 +382,17 @@ func (f *promiseImpl) IsCompleted() bool {
 }
 
 func (f *promiseImpl) IsCanceled() bool {
-	select {
-	case <-f.context.Done():
-		return true
-	default:
-		return false
-	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.state == canceled
 }
 
 func (f *promiseImpl) Cancel() error {
-	err := f.Fail(ErrorCanceled)
-	if f.IsCanceled() {
-		return ErrorCanceled
-	}
-	f.cancel()
-	return err
+	return f.complete(canceled, nil, ErrorCanceled, true)
 }
 
 // CancelCtx returns a channel that is closed when the work held in this Promise has to be
 // canceled.
 func (f *promiseImpl) CancelCtx() <-chan struct{} {
-	return f.context.Done()
+	return f.canceled
 }
@@ -0,0 +1,221 @@
+package manana
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests are intended to be run with the -race flag. They hammer concurrently all the
+// methods of the Future and Promise interfaces, and verify that a promise transitions exactly
+// once and that no callback is lost.
+
+const (
+	raceIterations = 200
+	raceWorkers    = 16
+)
+
+func TestRace_ExactlyOneTransition(t *testing.T) {
+	assert.NoError(t, eventually(20*time.Second, func() {
+		for i := 0; i < raceIterations; i++ {
+			// Given a promise
+			p := NewPromise()
+
+			// When many goroutines try to complete it at the same time
+			var transitions int32
+			start := make(chan struct{})
+			wg := sync.WaitGroup{}
+			wg.Add(raceWorkers)
+			for w := 0; w < raceWorkers; w++ {
+				w := w
+				go func() {
+					defer wg.Done()
+					<-start
+					var err error
+					switch w % 3 {
+					case 0:
+						err = p.Success(w)
+					case 1:
+						err = p.Fail(errors.New("catapun"))
+					case 2:
+						err = p.Cancel()
+					}
+					if err == nil {
+						atomic.AddInt32(&transitions, 1)
+					} else if err != ErrorCompleted && err != ErrorCanceled {
+						assert.Fail(t, "unexpected error", err.Error())
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			// Only one of them succeeds
+			assert.EqualValues(t, 1, atomic.LoadInt32(&transitions))
+			assert.True(t, p.IsCompleted())
+		}
+	}))
+}
+
+func TestRace_NoCallbackLost(t *testing.T) {
+	assert.NoError(t, eventually(20*time.Second, func() {
+		for i := 0; i < raceIterations; i++ {
+			// Given a promise
+			p := NewPromise()
+
+			// When callbacks are registered while the promise is being completed
+			var registeredSuccess, registeredFail, registeredComplete int32
+			var calledSuccess, calledFail, calledComplete int32
+			calls := sync.WaitGroup{}
+			start := make(chan struct{})
+			wg := sync.WaitGroup{}
+			wg.Add(raceWorkers + 1)
+			for w := 0; w < raceWorkers; w++ {
+				go func() {
+					defer wg.Done()
+					<-start
+					calls.Add(3)
+					atomic.AddInt32(&registeredSuccess, 1)
+					p.OnSuccess(func(_ interface{}) {
+						atomic.AddInt32(&calledSuccess, 1)
+						calls.Done()
+					})
+					atomic.AddInt32(&registeredFail, 1)
+					p.OnFail(func(_ error) {
+						atomic.AddInt32(&calledFail, 1)
+						calls.Done()
+					})
+					atomic.AddInt32(&registeredComplete, 1)
+					p.OnComplete(func(_ interface{}, _ error) {
+						atomic.AddInt32(&calledComplete, 1)
+						calls.Done()
+					})
+				}()
+			}
+			go func() {
+				defer wg.Done()
+				<-start
+				if i%2 == 0 {
+					p.Success(i)
+				} else {
+					p.Fail(errors.New("catapun"))
+				}
+			}()
+			close(start)
+			wg.Wait()
+
+			// Then all the callbacks matching the final status are invoked
+			done := make(chan struct{})
+			go func() {
+				// discount the callbacks that must never be invoked
+				if i%2 == 0 {
+					calls.Add(-int(atomic.LoadInt32(&registeredFail)))
+				} else {
+					calls.Add(-int(atomic.LoadInt32(&registeredSuccess)))
+				}
+				calls.Wait()
+				close(done)
+			}()
+			<-done
+			if i%2 == 0 {
+				assert.Equal(t, registeredSuccess, atomic.LoadInt32(&calledSuccess))
+				assert.Zero(t, atomic.LoadInt32(&calledFail))
+			} else {
+				assert.Equal(t, registeredFail, atomic.LoadInt32(&calledFail))
+				assert.Zero(t, atomic.LoadInt32(&calledSuccess))
+			}
+			assert.Equal(t, registeredComplete, atomic.LoadInt32(&calledComplete))
+		}
+	}))
+}
+
+func TestRace_AllMethods(t *testing.T) {
+	assert.NoError(t, eventually(20*time.Second, func() {
+		for i := 0; i < raceIterations; i++ {
+			// Given a promise
+			p := NewPromise()
+
+			// When all its methods are invoked concurrently
+			start := make(chan struct{})
+			wg := sync.WaitGroup{}
+			wg.Add(raceWorkers)
+			for w := 0; w < raceWorkers; w++ {
+				w := w
+				go func() {
+					defer wg.Done()
+					<-start
+					switch w % 8 {
+					case 0:
+						p.Success(w)
+					case 1:
+						p.Fail(errors.New("catapun"))
+					case 2:
+						p.Cancel()
+					case 3:
+						p.OnSuccess(func(_ interface{}) {})
+						p.OnFail(func(_ error) {})
+						p.OnComplete(func(_ interface{}, _ error) {})
+					case 4:
+						p.Get()
+					case 5:
+						p.Eventually(10 * time.Second)
+					case 6:
+						p.IsCompleted()
+						p.IsCanceled()
+					case 7:
+						select {
+						case <-p.CancelCtx():
+						default:
+						}
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			// Then the promise ends in a consistent state
+			val, err := p.Get()
+			assert.True(t, p.IsCompleted())
+			if p.IsCanceled() {
+				assert.Equal(t, ErrorCanceled, err)
+				assert.Nil(t, val)
+			} else if err != nil {
+				assert.EqualError(t, err, "catapun")
+				assert.Nil(t, val)
+			} else {
+				assert.NotNil(t, val)
+			}
+		}
+	}))
+}
+
+func TestRace_Cancel_ClosesCancelCtx(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a completed promise
+		p := NewPromise()
+		assert.NoError(t, p.Success(1))
+
+		// When trying to cancel it
+		assert.Equal(t, ErrorCompleted, p.Cancel())
+
+		// The promise keeps its successful status
+		assert.False(t, p.IsCanceled())
+		val, err := p.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, val)
+		select {
+		case <-p.CancelCtx():
+			assert.Fail(t, "cancel context should not have been closed")
+		default:
+		}
+
+		// But canceling a pending promise closes its cancel context
+		p = NewPromise()
+		assert.NoError(t, p.Cancel())
+		<-p.CancelCtx()
+	}))
+}
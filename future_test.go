@@ -385,6 +385,8 @@ func TestFuture_OnComplete_Error(t *testing.T) {
 		wg.Wait()
 		assert.EqualError(t, err1, "catapun")
 		assert.EqualError(t, err2, "catapun")
+		assert.Nil(t, val1)
+		assert.Nil(t, val2)
 	}))
 }
 
@@ -421,6 +423,8 @@ func TestFuture_OnComplete_Error_OneBeforeOneAfterSubscribing(t *testing.T) {
 		wg.Wait()
 		assert.EqualError(t, err1, "catapun")
 		assert.EqualError(t, err2, "catapun")
+		assert.Nil(t, val1)
+		assert.Nil(t, val2)
 	}))
 }
 
@@ -456,6 +460,8 @@ func TestFuture_OnComplete_Error_AfterSubscribing(t *testing.T) {
 		wg.Wait()
 		assert.EqualError(t, err1, "catapun")
 		assert.EqualError(t, err2, "catapun")
+		assert.Nil(t, val1)
+		assert.Nil(t, val2)
 	}))
 }
 
@@ -1063,7 +1069,7 @@ func TestCancel_Cancelable(t *testing.T) {
 		f := func() Promise {
 			p := NewPromise()
 			go func() {
-				<-p.CancelCh()
+				<-p.CancelCtx()
 				wg.Done()
 			}()
 			return p
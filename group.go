@@ -0,0 +1,90 @@
+package manana
+
+import "sync"
+
+// Group deduplicates the concurrent executions of functions that are identified by the same key.
+// While the function for a key is running, new invocations for the same key do not start it again,
+// but wait for the result of the running function.
+//
+// Each invocation returns its own Future. Canceling it only cancels the invocation, and the shared
+// function is canceled when the futures of all its invocations have been canceled.
+//
+// The zero value of Group is ready to use.
+type Group struct {
+	mutex sync.Mutex
+	calls map[string]*sharedCall
+}
+
+type sharedCall struct {
+	future Future
+	// refs is the number of invocations that have not been canceled
+	refs int
+}
+
+// Do runs a synchronous function in background, as manana.Do does, unless a function for the same
+// key is already running. In that case, the returned Future completes with its result.
+func (g *Group) Do(key string, syncFunc func() (interface{}, error)) Future {
+	return g.DoCtx(key, func(_ <-chan struct{}) (interface{}, error) {
+		return syncFunc()
+	})
+}
+
+// DoCtx runs a cancelable function in background, as manana.DoCtx does, unless a function for the
+// same key is already running. In that case, the returned Future completes with its result. The
+// cancel channel of the function is closed when all the futures returned for its key have been
+// canceled.
+func (g *Group) DoCtx(key string, asyncFunc func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*sharedCall{}
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &sharedCall{}
+		g.calls[key] = call
+		call.future = DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+			defer g.forget(key, call)
+			return asyncFunc(cancelCtx)
+		})
+		// the function is not invoked if it is canceled before starting
+		call.future.OnComplete(func(_ interface{}, _ error) {
+			g.forget(key, call)
+		})
+	}
+	call.refs++
+	g.mutex.Unlock()
+
+	p := NewPromise()
+	call.future.OnComplete(func(value interface{}, err error) {
+		complete(p, value, err)
+	})
+	p.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			g.release(key, call)
+		}
+	})
+	return p
+}
+
+// forget removes the call for the key, so the next invocations start the function again
+func (g *Group) forget(key string, call *sharedCall) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
+
+// release decrements the references to a call, canceling it when no references are left
+func (g *Group) release(key string, call *sharedCall) {
+	g.mutex.Lock()
+	call.refs--
+	canceled := call.refs == 0
+	if canceled && g.calls[key] == call {
+		delete(g.calls, key)
+	}
+	g.mutex.Unlock()
+	if canceled {
+		call.future.Cancel()
+	}
+}
@@ -0,0 +1,78 @@
+package manana
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Shared(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function that is invoked concurrently for the same key
+		var g Group
+		var runs int32
+		release := make(chan struct{})
+		fn := func() (interface{}, error) {
+			<-release
+			return atomic.AddInt32(&runs, 1), nil
+		}
+		f1 := g.Do("key", fn)
+		f2 := g.Do("key", fn)
+		other := g.Do("other", fn)
+
+		// The function only runs once per key
+		close(release)
+		val, err := All(f1, f2).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, val.([]interface{})[0], val.([]interface{})[1])
+		_, err = other.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+
+		// And it runs again once it has finished
+		assert.Eventually(t, func() bool {
+			val, err := g.Do("key", fn).Get()
+			return err == nil && val == int32(3)
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestGroup_Cancel(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a cancelable function shared by two invocations
+		var g Group
+		started := make(chan struct{})
+		interrupted := make(chan struct{})
+		fn := func(cancelCtx <-chan struct{}) (interface{}, error) {
+			close(started)
+			<-cancelCtx
+			close(interrupted)
+			return nil, ErrorCanceled
+		}
+		f1 := g.DoCtx("key", fn)
+		f2 := g.DoCtx("key", fn)
+		<-started
+
+		// When only one of the invocations is canceled
+		assert.NoError(t, f1.Cancel())
+		_, err := f1.Get()
+		assert.Equal(t, ErrorCanceled, err)
+
+		// The shared function keeps running
+		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-interrupted:
+			assert.Fail(t, "the function should not have been canceled")
+		default:
+		}
+		assert.False(t, f2.IsCompleted())
+
+		// When all the invocations are canceled
+		assert.NoError(t, f2.Cancel())
+
+		// The shared function is canceled
+		<-interrupted
+	})
+}
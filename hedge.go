@@ -0,0 +1,120 @@
+package manana
+
+import (
+	"sync"
+	"time"
+)
+
+// HedgedFuture is the Future returned by the Hedge function. It reports which attempt provided
+// its success value.
+type HedgedFuture interface {
+	Future
+	// Winner returns the number of the attempt whose value the Future succeeded with, starting at
+	// 1, or 0 if the Future has not succeeded.
+	Winner() int
+}
+
+// Hedge runs a cancelable function in background, as DoCtx does, and starts new concurrent
+// attempts of the same function after each of the passed delays, if none of the previous attempts
+// has succeeded yet. It is intended to reduce the tail latency of calls to replicated services.
+// Each delay is counted from the start of the previous attempt. If an attempt fails, the next
+// attempt is started immediately, without waiting for its delay.
+//
+// The returned Future succeeds with the value of the first successful attempt, and the rest of
+// attempts are canceled. If all the attempts fail, it fails with an *AggregateError that contains
+// their errors. Canceling the returned Future cancels all the attempts in progress.
+func Hedge(delays []time.Duration, fn func(cancel <-chan struct{}) (interface{}, error)) HedgedFuture {
+	h := &hedgedPromise{Promise: NewPromise(), delays: delays, fn: fn}
+	h.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			h.mutex.Lock()
+			attempts := h.stop()
+			h.mutex.Unlock()
+			cancelAllBut(attempts, -1)
+		}
+	})
+	h.mutex.Lock()
+	h.launch()
+	h.mutex.Unlock()
+	return h
+}
+
+type hedgedPromise struct {
+	Promise
+	delays []time.Duration
+	fn     func(cancel <-chan struct{}) (interface{}, error)
+	// mutex protects the following fields
+	mutex    sync.Mutex
+	attempts []Future
+	errs     []error
+	winner   int
+	timer    *time.Timer
+}
+
+func (h *hedgedPromise) wrapped() Future {
+	return h.Promise
+}
+
+func (h *hedgedPromise) Winner() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.winner
+}
+
+// launch starts a new attempt, if there are attempts left, and schedules the next one. It must
+// be invoked with the mutex locked.
+func (h *hedgedPromise) launch() {
+	if h.IsCompleted() || len(h.attempts) > len(h.delays) {
+		return
+	}
+	attempt := len(h.attempts) + 1
+	f := DoCtx(h.fn)
+	h.attempts = append(h.attempts, f)
+	f.OnComplete(func(value interface{}, err error) {
+		h.attemptDone(attempt, value, err)
+	})
+	if attempt <= len(h.delays) {
+		h.timer = time.AfterFunc(h.delays[attempt-1], func() {
+			h.mutex.Lock()
+			defer h.mutex.Unlock()
+			if len(h.attempts) == attempt {
+				h.launch()
+			}
+		})
+	}
+}
+
+func (h *hedgedPromise) attemptDone(attempt int, value interface{}, err error) {
+	h.mutex.Lock()
+	if h.IsCompleted() {
+		h.mutex.Unlock()
+		return
+	}
+	if err == nil {
+		h.winner = attempt
+		if h.Success(value) != nil {
+			h.winner = 0
+		}
+		attempts := h.stop()
+		h.mutex.Unlock()
+		cancelAllBut(attempts, attempt-1)
+		return
+	}
+	defer h.mutex.Unlock()
+	h.errs = append(h.errs, err)
+	if len(h.errs) == len(h.delays)+1 {
+		h.Fail(&AggregateError{Errors: h.errs})
+	} else if attempt == len(h.attempts) {
+		h.timer.Stop()
+		h.launch()
+	}
+}
+
+// stop prevents launching new attempts, and returns the attempts already launched. It must be
+// invoked with the mutex locked.
+func (h *hedgedPromise) stop() []Future {
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	return h.attempts
+}
@@ -0,0 +1,109 @@
+package manana
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedge_FirstAttemptWins(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function that responds before the hedging delay
+		var attempts int32
+		f := Hedge([]time.Duration{time.Hour}, func(_ <-chan struct{}) (interface{}, error) {
+			return atomic.AddInt32(&attempts, 1), nil
+		})
+
+		// No more attempts are started
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), val)
+		assert.Equal(t, 1, f.Winner())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+}
+
+func TestHedge_SlowAttempt(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function whose first attempt is slow
+		var attempts int32
+		interrupted := make(chan struct{})
+		f := Hedge([]time.Duration{10 * time.Millisecond, time.Hour},
+			func(cancel <-chan struct{}) (interface{}, error) {
+				if atomic.AddInt32(&attempts, 1) == 1 {
+					<-cancel
+					close(interrupted)
+					return nil, ErrorCanceled
+				}
+				return "fast", nil
+			})
+
+		// The hedged attempt provides the value
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "fast", val)
+		assert.Equal(t, 2, f.Winner())
+
+		// And the slow attempt is canceled
+		<-interrupted
+	})
+}
+
+func TestHedge_FailedAttempt(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function whose first attempt fails
+		var attempts int32
+		f := Hedge([]time.Duration{time.Hour}, func(_ <-chan struct{}) (interface{}, error) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return nil, errors.New("catapun")
+			}
+			return "ok", nil
+		})
+
+		// The next attempt starts without waiting for its delay
+		val, err := f.Eventually(time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", val)
+		assert.Equal(t, 2, f.Winner())
+	})
+}
+
+func TestHedge_AllFail(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function that always fails
+		f := Hedge([]time.Duration{time.Millisecond, time.Millisecond},
+			func(_ <-chan struct{}) (interface{}, error) {
+				return nil, errors.New("catapun")
+			})
+
+		// The future fails with the errors of all the attempts
+		_, err := f.Get()
+		assert.IsType(t, &AggregateError{}, err)
+		assert.Len(t, err.(*AggregateError).Errors, 3)
+		assert.Equal(t, 0, f.Winner())
+	})
+}
+
+func TestHedge_Cancel(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function whose attempts never finish
+		var attempts int32
+		f := Hedge([]time.Duration{5 * time.Millisecond, time.Hour},
+			func(cancel <-chan struct{}) (interface{}, error) {
+				atomic.AddInt32(&attempts, 1)
+				<-cancel
+				return nil, ErrorCanceled
+			})
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&attempts) == 2
+		}, time.Second, time.Millisecond)
+
+		// When the future is canceled, all the attempts are canceled
+		assert.NoError(t, f.Cancel())
+		_, err := f.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	})
+}
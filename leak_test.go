@@ -0,0 +1,125 @@
+package manana
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertNoLeaks runs the test function and verifies that, after it returns, all the goroutines
+// it started eventually finish. On failure, it reports the stack traces of all the goroutines.
+func assertNoLeaks(t *testing.T, test func()) {
+	before := runtime.NumGoroutine()
+	test()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			assert.Fail(t, fmt.Sprintf("%d goroutines leaked", runtime.NumGoroutine()-before),
+				string(buf))
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLeaks_DoCtx_Cancel(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a cancelable function that is running
+		started := make(chan struct{})
+		f := DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+			close(started)
+			<-cancelCtx
+			return nil, ErrorCanceled
+		})
+		<-started
+
+		// When the future is canceled
+		assert.NoError(t, f.Cancel())
+		_, err := f.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	})
+}
+
+func TestLeaks_Do_Cancel(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given an uncancelable function that is running
+		started := make(chan struct{})
+		release := make(chan struct{})
+		f := Do(func() (interface{}, error) {
+			close(started)
+			<-release
+			return "too late", nil
+		})
+		<-started
+
+		// When the future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// And the function returns after the cancellation
+		close(release)
+		_, err := f.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	})
+}
+
+func TestLeaks_Eventually_Timeout(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a future that takes long to complete
+		p := NewPromise()
+
+		// When waiting for it times out
+		_, err := p.Eventually(10 * time.Millisecond)
+		assert.Equal(t, ErrorTimeout, err)
+
+		// And the future completes later
+		assert.NoError(t, p.Success(1))
+		val, err := p.Eventually(time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, val)
+	})
+}
+
+func TestLeaks_DoContext_Timeout(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a context-aware function whose parent context times out
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		f := DoContext(ctx, func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		_, err := f.Get()
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
+
+func TestLeaks_Composite_Cancel(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a tree of composite futures waiting for running functions
+		leaf := func() Future {
+			return DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+				<-cancelCtx
+				return nil, ErrorCanceled
+			})
+		}
+		root := Then(All(
+			AllSettled(leaf(), leaf()),
+			Any([]Future{leaf(), leaf()}),
+			Race([]Future{leaf()}),
+		), func(value interface{}) (interface{}, error) {
+			return value, nil
+		})
+
+		// When the root is canceled
+		assert.NoError(t, root.Cancel())
+		_, err := root.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	})
+}
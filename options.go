@@ -0,0 +1,62 @@
+package manana
+
+// RecoverOption modifies the default behavior of the Recover, RecoverWith and MapError functions.
+type RecoverOption int
+
+const (
+	// RecoverCanceled makes the Recover, RecoverWith and MapError functions to also handle the
+	// ErrorCanceled error of canceled futures. By default, cancellation is propagated and never
+	// recovered.
+	RecoverCanceled RecoverOption = iota + 1
+)
+
+// SelectOption modifies the default behavior of the Any, Race and Some functions.
+type SelectOption int
+
+const (
+	// CancelLosers makes the Any, Race and Some functions to cancel the argument futures that are
+	// still running once the result of the returned Future has been decided.
+	CancelLosers SelectOption = iota + 1
+)
+
+// TimeoutOption modifies the default behavior of the WithTimeout and WithDeadline functions.
+type TimeoutOption int
+
+const (
+	// KeepRunning makes the WithTimeout and WithDeadline functions to leave the argument future
+	// running when the timeout is reached, instead of canceling it.
+	KeepRunning TimeoutOption = iota + 1
+)
+
+// PeriodicOption modifies the default behavior of the Every function.
+type PeriodicOption int
+
+const (
+	// FixedDelay makes the Every function to wait the interval between the end of a run and the
+	// start of the next one, instead of starting the runs at a fixed rate.
+	FixedDelay PeriodicOption = iota + 1
+
+	// QueueOverruns makes the Every function to delay the runs whose time is reached while the
+	// previous run is still in progress, and start them as soon as it finishes. By default, such
+	// runs are skipped.
+	QueueOverruns
+
+	// OverlapRuns makes the Every function to start the runs at their time, even if the previous
+	// runs are still in progress. By default, such runs are skipped.
+	OverlapRuns
+
+	// BufferRuns makes the Runs channel of a Periodic to deliver the futures of all the runs,
+	// keeping them in an unbounded buffer until they are received. By default, the futures that
+	// can't be delivered immediately are discarded.
+	BufferRuns
+)
+
+// hasOption returns true if the option is contained in the options slice
+func hasOption[O comparable](options []O, option O) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package manana
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is the error of a Future whose wrapped function panicked. It holds the recovered
+// value and the stack trace of the goroutine at the moment of the panic.
+type PanicError struct {
+	// Value is the value that was passed to panic
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the recovered value if it is an error, so it can be inspected with errors.Is and
+// errors.As.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// CallbackPanicPolicy specifies what happens when a callback passed to the OnSuccess, OnFail or
+// OnComplete methods panics.
+type CallbackPanicPolicy int
+
+const (
+	// LogPanic recovers the panic and logs it, with its stack trace, to the standard logger.
+	LogPanic CallbackPanicPolicy = iota
+	// HandlePanic recovers the panic and forwards it, as a *PanicError, to the global handler set
+	// with SetPanicHandler.
+	HandlePanic
+	// RePanic does not recover the panic, so it crashes the process as any unrecovered panic.
+	RePanic
+)
+
+var panicConfig = struct {
+	sync.RWMutex
+	policy  CallbackPanicPolicy
+	handler func(err *PanicError)
+}{policy: LogPanic}
+
+// SetCallbackPanicPolicy sets the behavior of the library when a callback panics. The default
+// policy is LogPanic.
+func SetCallbackPanicPolicy(policy CallbackPanicPolicy) {
+	panicConfig.Lock()
+	defer panicConfig.Unlock()
+	panicConfig.policy = policy
+}
+
+// SetPanicHandler sets the global handler that receives the panics of the callbacks when the
+// HandlePanic policy is set. If no handler is set, the panics are logged.
+func SetPanicHandler(handler func(err *PanicError)) {
+	panicConfig.Lock()
+	defer panicConfig.Unlock()
+	panicConfig.handler = handler
+}
+
+// dispatch runs a callback asynchronously, handling its panics as specified by the
+// CallbackPanicPolicy.
+func dispatch(callback func()) {
+	go runCallback(callback)
+}
+
+func runCallback(callback func()) {
+	panicConfig.RLock()
+	policy, handler := panicConfig.policy, panicConfig.handler
+	panicConfig.RUnlock()
+	if policy != RePanic {
+		defer func() {
+			if r := recover(); r != nil {
+				err := &PanicError{Value: r, Stack: debug.Stack()}
+				if policy == HandlePanic && handler != nil {
+					handler(err)
+				} else {
+					log.Printf("manana: recovered panic in callback: %v\n%s", err.Value, err.Stack)
+				}
+			}
+		}()
+	}
+	callback()
+}
+
+// safeCall invokes a wrapped function, converting its panics into a *PanicError.
+func safeCall(fn func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// safeFuture invokes a function that returns a Future, converting its panics into a *PanicError,
+// and a nil Future into ErrorNilFuture.
+func safeFuture(fn func() Future) (Future, error) {
+	value, err := safeCall(func() (interface{}, error) {
+		return fn(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	future, _ := value.(Future)
+	if future == nil {
+		return nil, ErrorNilFuture
+	}
+	return future, nil
+}
@@ -0,0 +1,106 @@
+package manana
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo_Panic(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that panics
+		fn := func() (interface{}, error) {
+			panic("boom")
+		}
+
+		// When executed asynchronously
+		f := Do(fn)
+
+		// The future fails with a PanicError instead of crashing the process
+		_, err := f.Get()
+		assert.IsType(t, &PanicError{}, err)
+		pErr := err.(*PanicError)
+		assert.Equal(t, "boom", pErr.Value)
+		assert.Contains(t, string(pErr.Stack), "TestDo_Panic")
+		assert.EqualError(t, err, "panic: boom")
+	}))
+}
+
+func TestDoCtx_PanicWithError(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a cancelable function that panics with an error
+		cause := errors.New("catapun")
+		f := DoCtx(func(_ <-chan struct{}) (interface{}, error) {
+			panic(cause)
+		})
+
+		// The future fails with a PanicError that wraps the error
+		_, err := f.Get()
+		assert.True(t, errors.Is(err, cause))
+	}))
+}
+
+func TestThen_Panic(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a continuation that panics
+		f := Then(Do(func() (interface{}, error) {
+			return 1, nil
+		}), func(_ interface{}) (interface{}, error) {
+			panic("boom")
+		})
+
+		// The returned future fails with a PanicError
+		_, err := f.Get()
+		assert.IsType(t, &PanicError{}, err)
+	}))
+}
+
+func TestCallbackPanic_Handler(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a global panic handler
+		panics := make(chan *PanicError, 1)
+		SetCallbackPanicPolicy(HandlePanic)
+		SetPanicHandler(func(err *PanicError) {
+			panics <- err
+		})
+		defer func() {
+			SetCallbackPanicPolicy(LogPanic)
+			SetPanicHandler(nil)
+		}()
+
+		// And a future with a callback that panics
+		p := NewPromise()
+		p.OnSuccess(func(_ interface{}) {
+			panic("boom")
+		})
+
+		// When the future succeeds
+		assert.NoError(t, p.Success(1))
+
+		// The panic is forwarded to the handler
+		err := <-panics
+		assert.Equal(t, "boom", err.Value)
+	}))
+}
+
+func TestCallbackPanic_Log(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future with a callback that panics, with the default policy
+		p := NewPromise()
+		p.OnFail(func(_ error) {
+			panic("boom")
+		})
+		done := make(chan struct{})
+		p.OnComplete(func(_ interface{}, _ error) {
+			close(done)
+		})
+
+		// When the future fails
+		assert.NoError(t, p.Fail(errors.New("catapun")))
+
+		// The process does not crash and the rest of callbacks are invoked
+		<-done
+	}))
+}
@@ -0,0 +1,137 @@
+package manana
+
+import (
+	"sync"
+	"time"
+)
+
+// Periodic is a handle to a function that runs periodically, as returned by the Every function.
+type Periodic struct {
+	runs   chan Future
+	stop   chan struct{}
+	exited chan struct{}
+	once   sync.Once
+}
+
+// Every runs a cancelable function periodically in the default Executor, until the Stop method
+// of the returned Periodic is invoked. The first run starts after the given interval.
+//
+// By default, the runs start at a fixed rate. If a run lasts longer than the interval, the runs
+// whose time is reached while it is in progress are skipped, unless the QueueOverruns or the
+// OverlapRuns options are passed. With the FixedDelay option, the interval is counted from the end
+// of each run, so runs never overlap.
+//
+// Every panics if the interval is not positive, or if both the QueueOverruns and the OverlapRuns
+// options are passed.
+//
+// Accepted options: FixedDelay, QueueOverruns, OverlapRuns, BufferRuns.
+func Every(interval time.Duration, fn func(cancelCtx <-chan struct{}) (interface{}, error), options ...PeriodicOption) *Periodic {
+	if interval <= 0 {
+		panic("manana: non-positive interval for Every")
+	}
+	if hasOption(options, QueueOverruns) && hasOption(options, OverlapRuns) {
+		panic("manana: QueueOverruns and OverlapRuns options can't be passed together to Every")
+	}
+	pr := &Periodic{
+		runs:   make(chan Future, 1),
+		stop:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	go pr.loop(interval, fn, options)
+	return pr
+}
+
+// Runs returns a channel that receives the Future of each run, when it starts. As in the
+// time.Ticker, the channel has a buffer of one element, and the futures of the runs that start
+// while the buffer is full are not delivered, unless the BufferRuns option is passed to Every. The
+// channel is closed after Stop is invoked.
+func (pr *Periodic) Runs() <-chan Future {
+	return pr.runs
+}
+
+// Stop ends the periodic execution, and cancels the runs that are in progress. No runs start after
+// Stop returns. Invoking Stop more than once has no effect.
+func (pr *Periodic) Stop() {
+	pr.once.Do(func() {
+		close(pr.stop)
+	})
+	<-pr.exited
+}
+
+func (pr *Periodic) loop(interval time.Duration, fn func(cancelCtx <-chan struct{}) (interface{}, error), options []PeriodicOption) {
+	defer close(pr.runs)
+	defer close(pr.exited)
+
+	fixedDelay := hasOption(options, FixedDelay)
+	var ticks <-chan time.Time
+	var timer *time.Timer
+	if fixedDelay {
+		timer = time.NewTimer(interval)
+		defer timer.Stop()
+		ticks = timer.C
+	} else {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	inProgress := map[Future]struct{}{}
+	ended := make(chan Future)
+	queued := 0
+	bufferRuns := hasOption(options, BufferRuns)
+	// undelivered keeps the futures that have not been received from Runs, with the BufferRuns
+	// option
+	var undelivered []Future
+	start := func() {
+		run := DefaultExecutor().DoCtx(fn)
+		inProgress[run] = struct{}{}
+		run.OnComplete(func(_ interface{}, _ error) {
+			select {
+			case ended <- run:
+			case <-pr.exited:
+			}
+		})
+		if bufferRuns {
+			undelivered = append(undelivered, run)
+			return
+		}
+		select {
+		case pr.runs <- run:
+		default:
+		}
+	}
+
+	for {
+		// the runs channel is only selected when there are futures to deliver
+		var runs chan<- Future
+		var next Future
+		if len(undelivered) > 0 {
+			runs, next = pr.runs, undelivered[0]
+		}
+		select {
+		case runs <- next:
+			undelivered[0] = nil
+			undelivered = undelivered[1:]
+		case <-pr.stop:
+			for run := range inProgress {
+				run.Cancel()
+			}
+			return
+		case <-ticks:
+			switch {
+			case len(inProgress) == 0 || hasOption(options, OverlapRuns):
+				start()
+			case hasOption(options, QueueOverruns):
+				queued++
+			}
+		case run := <-ended:
+			delete(inProgress, run)
+			if fixedDelay {
+				timer.Reset(interval)
+			} else if queued > 0 {
+				queued--
+				start()
+			}
+		}
+	}
+}
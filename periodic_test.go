@@ -0,0 +1,193 @@
+package manana
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvery(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function that runs periodically
+		var count int32
+		pr := Every(10*time.Millisecond, func(_ <-chan struct{}) (interface{}, error) {
+			return atomic.AddInt32(&count, 1), nil
+		})
+
+		// The result of each run is provided as a future
+		for i := 0; i < 3; i++ {
+			val, err := (<-pr.Runs()).Get()
+			assert.NoError(t, err)
+			assert.True(t, val.(int32) > 0)
+		}
+
+		// When it is stopped
+		pr.Stop()
+
+		// No more runs are started
+		stopped := atomic.LoadInt32(&count)
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, stopped, atomic.LoadInt32(&count))
+		for range pr.Runs() {
+		}
+	})
+}
+
+// blockingRuns returns a function that counts its runs and blocks until the release channel is
+// closed
+func blockingRuns(count *int32, release <-chan struct{}) func(_ <-chan struct{}) (interface{}, error) {
+	return func(cancelCtx <-chan struct{}) (interface{}, error) {
+		atomic.AddInt32(count, 1)
+		select {
+		case <-release:
+			return nil, nil
+		case <-cancelCtx:
+			return nil, ErrorCanceled
+		}
+	}
+}
+
+// waitFirstRun waits until the first run of a periodic function counted by blockingRuns starts
+func waitFirstRun(t *testing.T, count *int32) {
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(count) >= 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestEvery_SkipOverruns(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a periodic function whose runs take longer than the interval
+		var count int32
+		release := make(chan struct{})
+		pr := Every(10*time.Millisecond, blockingRuns(&count, release))
+		waitFirstRun(t, &count)
+
+		// The runs that overlap with the run in progress are skipped
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+		close(release)
+		pr.Stop()
+	})
+}
+
+func TestEvery_QueueOverruns(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a periodic function whose runs take longer than the interval, and are queued
+		var count int32
+		release := make(chan struct{})
+		pr := Every(10*time.Millisecond, blockingRuns(&count, release), QueueOverruns)
+		waitFirstRun(t, &count)
+
+		// The runs are not started while the run in progress does not finish
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+		// When the run in progress finishes
+		close(release)
+
+		// The queued runs are started
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&count) >= 4
+		}, time.Second, time.Millisecond)
+		pr.Stop()
+	})
+}
+
+func TestEvery_OverlapRuns(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a periodic function whose runs take longer than the interval, and can overlap
+		var count int32
+		pr := Every(10*time.Millisecond, blockingRuns(&count, nil), OverlapRuns)
+
+		// The runs are started even if the previous are in progress
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&count) >= 3
+		}, time.Second, time.Millisecond)
+
+		// And stopping the schedule cancels all of them
+		pr.Stop()
+	})
+}
+
+func TestEvery_BufferRuns(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a periodic function whose runs are buffered
+		var count int32
+		pr := Every(5*time.Millisecond, func(_ <-chan struct{}) (interface{}, error) {
+			return atomic.AddInt32(&count, 1), nil
+		}, BufferRuns)
+
+		// When many runs start before their futures are received
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&count) >= 5
+		}, time.Second, time.Millisecond)
+
+		// The futures of all the runs are delivered, in order
+		for i := int32(1); i <= 5; i++ {
+			val, err := (<-pr.Runs()).Get()
+			assert.NoError(t, err)
+			assert.Equal(t, i, val)
+		}
+		pr.Stop()
+	})
+}
+
+func TestEvery_InvalidArguments(t *testing.T) {
+	fn := func(_ <-chan struct{}) (interface{}, error) { return nil, nil }
+	// A non-positive interval is not accepted
+	assert.Panics(t, func() { Every(0, fn) })
+	// Nor incompatible options
+	assert.Panics(t, func() { Every(time.Second, fn, QueueOverruns, OverlapRuns) })
+}
+
+func TestEvery_FixedDelay(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a periodic function that runs with a fixed delay
+		var mutex sync.Mutex
+		var ends, starts []time.Time
+		pr := Every(20*time.Millisecond, func(_ <-chan struct{}) (interface{}, error) {
+			mutex.Lock()
+			starts = append(starts, time.Now())
+			mutex.Unlock()
+			time.Sleep(30 * time.Millisecond)
+			mutex.Lock()
+			ends = append(ends, time.Now())
+			mutex.Unlock()
+			return nil, nil
+		}, FixedDelay)
+
+		for i := 0; i < 3; i++ {
+			_, err := (<-pr.Runs()).Get()
+			assert.NoError(t, err)
+		}
+		pr.Stop()
+
+		// The interval is counted from the end of each run
+		mutex.Lock()
+		defer mutex.Unlock()
+		for i := 1; i < 3; i++ {
+			assert.True(t, starts[i].Sub(ends[i-1]) >= 20*time.Millisecond)
+		}
+	})
+}
+
+func TestEvery_StopCancelsRun(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a periodic function with a run in progress
+		var count int32
+		pr := Every(10*time.Millisecond, blockingRuns(&count, nil))
+		run := <-pr.Runs()
+
+		// When the schedule is stopped
+		pr.Stop()
+
+		// The run in progress is canceled
+		_, err := run.Get()
+		assert.Equal(t, ErrorCanceled, err)
+		assert.True(t, run.IsCanceled())
+	})
+}
@@ -0,0 +1,133 @@
+package manana
+
+import (
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of the progress of the operation held in a Future.
+type Progress struct {
+	// Done is the number of units of work (e.g. downloaded bytes) that are already done
+	Done int64
+	// Total is the total number of units of work, or 0 if it is unknown
+	Total int64
+}
+
+// Fraction returns the done fraction of the work, between 0 and 1, or 0 if the total is unknown.
+func (p Progress) Fraction() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Done) / float64(p.Total)
+}
+
+// DoProgress wraps a synchronous function into an asynchronous envelope, as Do does. The wrapped
+// function receives a report function to periodically notify its progress, which can be queried
+// with the Progress and OnProgress methods of the returned Future.
+//
+// The function is run by the default Executor (see SetDefaultExecutor).
+func DoProgress(fn func(report func(done, total int64)) (interface{}, error)) Future {
+	return DefaultExecutor().DoProgress(fn)
+}
+
+// progressListener throttles the invocations of a progress callback
+type progressListener struct {
+	mutex    sync.Mutex
+	callback func(_ Progress)
+	throttle time.Duration
+	// dispatcher delivers the progress reports in order
+	dispatcher Dispatcher
+	last       time.Time
+	latest     Progress
+	// timer delivers the latest report at the end of the throttle interval, if it is not nil
+	timer *time.Timer
+	// stopped is set when the future completes, so no more reports are delivered
+	stopped bool
+}
+
+// notify invokes the callback with the reported progress, unless it has been invoked during the
+// last throttle interval. In that case, the invocation is delayed until the interval ends, with
+// the latest progress reported by then.
+func (l *progressListener) notify(progress Progress) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stopped {
+		return
+	}
+	l.latest = progress
+	if l.timer != nil {
+		return
+	}
+	wait := l.throttle - time.Since(l.last)
+	if wait <= 0 {
+		l.last = time.Now()
+		l.dispatcher.Dispatch(func() { l.callback(progress) })
+		return
+	}
+	l.timer = time.AfterFunc(wait, func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.timer = nil
+		if l.stopped {
+			return
+		}
+		l.last = time.Now()
+		latest := l.latest
+		l.dispatcher.Dispatch(func() { l.callback(latest) })
+	})
+}
+
+// stop discards the progress report that is waiting for the end of the throttle interval, and
+// ignores the following reports. The reports that were already dispatched are not affected.
+func (l *progressListener) stop() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.stopped = true
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+func (f *promiseImpl) Progress() Progress {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.progress
+}
+
+func (f *promiseImpl) OnProgress(callback func(_ Progress), throttle time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.state != pending {
+		return
+	}
+	// Async would deliver each report in its own goroutine, so the reports could arrive out of order
+	dispatcher := f.dispatcher
+	if dispatcher == Async {
+		dispatcher = NewSerialDispatcher()
+	}
+	f.progressCBs = append(f.progressCBs, &progressListener{
+		callback:   callback,
+		throttle:   throttle,
+		dispatcher: dispatcher,
+	})
+}
+
+func (f *promiseImpl) ReportProgress(done, total int64) error {
+	f.mutex.Lock()
+	switch f.state {
+	case canceled:
+		f.mutex.Unlock()
+		return ErrorCanceled
+	case succeeded, failed:
+		f.mutex.Unlock()
+		return ErrorCompleted
+	}
+	f.progress = Progress{Done: done, Total: total}
+	progress, listeners := f.progress, f.progressCBs
+	f.mutex.Unlock()
+	for _, l := range listeners {
+		l.notify(progress)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package manana
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoProgress(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a long-running function that reports its progress
+		step := make(chan struct{})
+		reported := make(chan struct{})
+		f := DoProgress(func(report func(done, total int64)) (interface{}, error) {
+			for i := int64(1); i <= 4; i++ {
+				<-step
+				report(i*25, 100)
+				reported <- struct{}{}
+			}
+			return "downloaded", nil
+		})
+
+		// When the function progresses
+		step <- struct{}{}
+		<-reported
+		// Its progress can be queried
+		assert.Equal(t, Progress{Done: 25, Total: 100}, f.Progress())
+		assert.Equal(t, 0.25, f.Progress().Fraction())
+
+		step <- struct{}{}
+		<-reported
+		assert.Equal(t, Progress{Done: 50, Total: 100}, f.Progress())
+
+		// Until it finishes
+		step <- struct{}{}
+		<-reported
+		step <- struct{}{}
+		<-reported
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "downloaded", val)
+		assert.Equal(t, Progress{Done: 100, Total: 100}, f.Progress())
+	}))
+}
+
+func TestOnProgress(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise with a progress subscription
+		p := NewPromise()
+		received := make(chan Progress, 10)
+		p.OnProgress(func(progress Progress) {
+			received <- progress
+		}, 0)
+
+		// When the progress is reported
+		assert.NoError(t, p.ReportProgress(1, 10))
+
+		// It is received by the subscriber
+		assert.Equal(t, Progress{Done: 1, Total: 10}, <-received)
+
+		// But progress can't be reported after completion
+		assert.NoError(t, p.Success(nil))
+		assert.Equal(t, ErrorCompleted, p.ReportProgress(2, 10))
+	}))
+}
+
+func TestOnProgress_Throttle(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise with a throttled progress subscription
+		p := NewPromise()
+		var mutex sync.Mutex
+		var received []Progress
+		p.OnProgress(func(progress Progress) {
+			mutex.Lock()
+			received = append(received, progress)
+			mutex.Unlock()
+		}, 100*time.Millisecond)
+
+		// When the progress is reported many times during the throttle interval
+		for i := int64(1); i <= 100; i++ {
+			assert.NoError(t, p.ReportProgress(i, 100))
+		}
+
+		// Only the first and the latest reports are delivered
+		assert.Eventually(t, func() bool {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return len(received) == 2
+		}, time.Second, 10*time.Millisecond)
+		time.Sleep(150 * time.Millisecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.Equal(t, []Progress{{Done: 1, Total: 100}, {Done: 100, Total: 100}}, received)
+	}))
+}
+
+func TestOnProgress_ThrottledAfterCompletion(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise with a throttled progress subscription
+		p := NewPromise()
+		received := make(chan Progress, 10)
+		p.OnProgress(func(progress Progress) {
+			received <- progress
+		}, 50*time.Millisecond)
+
+		// When a report is throttled and the promise completes before the throttle interval ends
+		assert.NoError(t, p.ReportProgress(1, 10))
+		assert.Equal(t, Progress{Done: 1, Total: 10}, <-received)
+		assert.NoError(t, p.ReportProgress(5, 10))
+		assert.NoError(t, p.Success(nil))
+
+		// The throttled report is not delivered
+		time.Sleep(100 * time.Millisecond)
+		assert.Empty(t, received)
+	}))
+}
+
+func TestOnProgress_Order(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a promise with an unthrottled progress subscription
+		p := NewPromise()
+		received := make(chan Progress, 100)
+		p.OnProgress(func(progress Progress) {
+			received <- progress
+		}, 0)
+
+		// When the progress is reported many times
+		for i := int64(1); i <= 100; i++ {
+			assert.NoError(t, p.ReportProgress(i, 100))
+		}
+
+		// The reports are delivered in order
+		for i := int64(1); i <= 100; i++ {
+			assert.Equal(t, Progress{Done: i, Total: 100}, <-received)
+		}
+	}))
+}
+
+func TestAll_Progress(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given two downloads of different sizes
+		small, big := NewPromise(), NewPromise()
+		all := All(small, big)
+		received := make(chan Progress, 10)
+		all.OnProgress(func(progress Progress) {
+			received <- progress
+		}, 0)
+
+		// When both report their progress
+		assert.NoError(t, small.ReportProgress(10, 10))
+		<-received
+		assert.NoError(t, big.ReportProgress(30, 90))
+		<-received
+
+		// The composite progress is weighted by the size of each download
+		assert.Equal(t, Progress{Done: 40, Total: 100}, all.Progress())
+		assert.Equal(t, 0.4, all.Progress().Fraction())
+	}))
+}
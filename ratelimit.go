@@ -0,0 +1,229 @@
+package manana
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// QueuedFuture is a Future whose function may wait in a queue before running, as the futures
+// returned by a RateLimiter.
+type QueuedFuture interface {
+	Future
+	// IsQueued returns true if the function of the Future is waiting to be started.
+	IsQueued() bool
+}
+
+// RateLimiterConfig specifies the rate of a RateLimiter.
+type RateLimiterConfig struct {
+	// Limit is the number of functions that can be started each Interval.
+	Limit int
+	// Interval is the period the Limit refers to. Default: 1 second.
+	Interval time.Duration
+	// Burst is the maximum number of functions that can be started at once, after a period of
+	// inactivity. Default: Limit.
+	Burst int
+	// Executor runs the functions once they are started. Default: an Executor that runs each
+	// function in a new goroutine, so the RateLimiter can be set as the default Executor.
+	Executor Executor
+	// Clock provides the time to the RateLimiter. Default: SystemClock.
+	Clock Clock
+}
+
+// RateLimiter is an Executor decorator that starts at most a given number of functions per
+// interval, according to a token bucket algorithm. The functions exceeding the rate wait in a FIFO
+// queue until they can be started. It can be used directly, or set as the default Executor with
+// SetDefaultExecutor to limit the functions run by Do, DoCtx, DoContext and DoProgress.
+//
+// The futures returned by a RateLimiter implement the QueuedFuture interface. Canceling a queued
+// Future releases its place in the queue, and its function never runs.
+type RateLimiter struct {
+	config RateLimiterConfig
+	// perToken is the time required to refill a token
+	perToken time.Duration
+	// mutex protects the following fields, and the queued field of the futures
+	mutex   sync.Mutex
+	tokens  float64
+	last    time.Time
+	queue   []*rateLimitedPromise
+	waiting bool
+}
+
+type rateLimitedPromise struct {
+	Promise
+	limiter *RateLimiter
+	fn      func() (interface{}, error)
+	queued  bool
+}
+
+func (p *rateLimitedPromise) wrapped() Future {
+	return p.Promise
+}
+
+func (p *rateLimitedPromise) IsQueued() bool {
+	p.limiter.mutex.Lock()
+	defer p.limiter.mutex.Unlock()
+	return p.queued
+}
+
+// NewRateLimiter creates a RateLimiter whose bucket is initially full, so it can start a burst of
+// functions immediately.
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	if config.Limit <= 0 {
+		config.Limit = 1
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	if config.Executor == nil {
+		config.Executor = goroutines
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+	return &RateLimiter{
+		config:   config,
+		perToken: config.Interval / time.Duration(config.Limit),
+		tokens:   float64(config.Burst),
+		last:     config.Clock.Now(),
+	}
+}
+
+// Do runs a synchronous function in background, as manana.Do does, when the rate allows it.
+func (r *RateLimiter) Do(syncFunc func() (interface{}, error)) Future {
+	p := &rateLimitedPromise{Promise: NewPromise(), limiter: r, fn: syncFunc}
+	return r.submit(p)
+}
+
+// DoCtx runs a cancelable function in background, as manana.DoCtx does, when the rate allows it.
+func (r *RateLimiter) DoCtx(asyncFunc func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	p := &rateLimitedPromise{Promise: NewPromise(), limiter: r}
+	p.fn = func() (interface{}, error) {
+		return asyncFunc(p.CancelCtx())
+	}
+	return r.submit(p)
+}
+
+// DoContext runs a context-aware function in background, as manana.DoContext does, when the rate
+// allows it.
+func (r *RateLimiter) DoContext(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) Future {
+	p := &rateLimitedPromise{Promise: NewPromiseWithContext(ctx), limiter: r}
+	p.fn = func() (interface{}, error) {
+		return fn(p.Context())
+	}
+	return r.submit(p)
+}
+
+// DoProgress runs a function that reports its progress in background, as manana.DoProgress does,
+// when the rate allows it.
+func (r *RateLimiter) DoProgress(fn func(report func(done, total int64)) (interface{}, error)) Future {
+	p := &rateLimitedPromise{Promise: NewPromise(), limiter: r}
+	p.fn = func() (interface{}, error) {
+		return fn(func(done, total int64) {
+			p.ReportProgress(done, total)
+		})
+	}
+	return r.submit(p)
+}
+
+// submit starts the function of the promise if there is a token available, or queues it
+func (r *RateLimiter) submit(p *rateLimitedPromise) Future {
+	r.mutex.Lock()
+	r.refill()
+	if len(r.queue) == 0 && r.tokens >= 1 {
+		r.tokens--
+		r.mutex.Unlock()
+		r.start(p)
+		return p
+	}
+	p.queued = true
+	r.queue = append(r.queue, p)
+	if !r.waiting {
+		r.waiting = true
+		go r.wait()
+	}
+	r.mutex.Unlock()
+	p.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			r.dequeue(p)
+		}
+	})
+	return p
+}
+
+// start runs the function of the promise in the Executor, unless the promise is already completed
+func (r *RateLimiter) start(p *rateLimitedPromise) {
+	r.config.Executor.Do(func() (interface{}, error) {
+		if !p.IsCompleted() {
+			value, err := safeCall(p.fn)
+			complete(p, value, err)
+		}
+		return nil, nil
+	}).OnFail(func(err error) {
+		// the executor rejected the function
+		p.Fail(err)
+	})
+}
+
+// wait starts the queued functions as the tokens are refilled, until the queue is empty
+func (r *RateLimiter) wait() {
+	for {
+		r.mutex.Lock()
+		r.refill()
+		var ready []*rateLimitedPromise
+		for len(r.queue) > 0 {
+			p := r.queue[0]
+			// the completed promises (e.g. canceled, or whose context is done) are discarded
+			// without consuming a token
+			if !p.IsCompleted() {
+				if r.tokens < 1 {
+					break
+				}
+				r.tokens--
+				ready = append(ready, p)
+			}
+			r.queue[0] = nil
+			r.queue = r.queue[1:]
+			p.queued = false
+		}
+		empty := len(r.queue) == 0
+		if empty {
+			r.waiting = false
+		}
+		delay := time.Duration(math.Ceil((1 - r.tokens) * float64(r.perToken)))
+		r.mutex.Unlock()
+		for _, p := range ready {
+			r.start(p)
+		}
+		if empty {
+			return
+		}
+		<-r.config.Clock.After(delay)
+	}
+}
+
+// refill adds the tokens corresponding to the time elapsed since the last refill. It must be
+// invoked with the mutex locked.
+func (r *RateLimiter) refill() {
+	now := r.config.Clock.Now()
+	elapsed := now.Sub(r.last)
+	r.last = now
+	r.tokens = math.Min(float64(r.config.Burst), r.tokens+float64(elapsed)/float64(r.perToken))
+}
+
+// dequeue removes a canceled promise from the queue, if it has not been started yet
+func (r *RateLimiter) dequeue(p *rateLimitedPromise) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, queued := range r.queue {
+		if queued == p {
+			r.queue = append(r.queue[:i], r.queue[i+1:]...)
+			p.queued = false
+			return
+		}
+	}
+}
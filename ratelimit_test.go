@@ -0,0 +1,145 @@
+package manana
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitTimers waits until the clock has a pending timer
+func waitTimers(t *testing.T, clock *manualClock) {
+	assert.Eventually(t, func() bool {
+		return clock.Timers() > 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a rate limiter that starts 2 functions per second
+		clock := newManualClock()
+		r := NewRateLimiter(RateLimiterConfig{Limit: 2, Interval: time.Second, Clock: clock})
+		var runs int32
+		fn := func() (interface{}, error) {
+			return atomic.AddInt32(&runs, 1), nil
+		}
+
+		// When more functions than the burst are submitted
+		f1, f2, f3 := r.Do(fn), r.Do(fn), r.Do(fn)
+
+		// The burst starts immediately
+		_, err := All(f1, f2).Get()
+		assert.NoError(t, err)
+		assert.False(t, f1.(QueuedFuture).IsQueued())
+
+		// And the rest wait in the queue
+		assert.True(t, f3.(QueuedFuture).IsQueued())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+
+		// Until a new token is available
+		waitTimers(t, clock)
+		clock.Advance(500 * time.Millisecond)
+		_, err = f3.Get()
+		assert.NoError(t, err)
+		assert.False(t, f3.(QueuedFuture).IsQueued())
+	})
+}
+
+func TestRateLimiter_Rate(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a rate limiter that starts 1 function each 10 milliseconds
+		r := NewRateLimiter(RateLimiterConfig{Limit: 1, Interval: 10 * time.Millisecond})
+		start := time.Now()
+
+		// When many functions are submitted
+		var futures []Future
+		for i := 0; i < 5; i++ {
+			futures = append(futures, r.DoCtx(func(_ <-chan struct{}) (interface{}, error) {
+				return nil, nil
+			}))
+		}
+
+		// They are started at the limited rate
+		_, err := All(futures...).Get()
+		assert.NoError(t, err)
+		assert.True(t, time.Since(start) >= 40*time.Millisecond)
+	})
+}
+
+func TestRateLimiter_CancelQueued(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a queued function
+		clock := newManualClock()
+		r := NewRateLimiter(RateLimiterConfig{Limit: 1, Interval: time.Second, Clock: clock})
+		var runs int32
+		fn := func(ctx context.Context) (interface{}, error) {
+			return atomic.AddInt32(&runs, 1), nil
+		}
+		_, err := r.DoContext(context.Background(), fn).Get()
+		assert.NoError(t, err)
+		queued := r.DoContext(context.Background(), fn)
+		next := r.DoContext(context.Background(), fn)
+
+		// When it is canceled
+		assert.NoError(t, queued.Cancel())
+		assert.Eventually(t, func() bool {
+			return !queued.(QueuedFuture).IsQueued()
+		}, time.Second, time.Millisecond)
+
+		// It releases its place in the queue without running
+		waitTimers(t, clock)
+		clock.Advance(time.Second)
+		val, err := next.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), val)
+	})
+}
+
+func TestRateLimiter_ContextDoneWhileQueued(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a queued function whose context is canceled
+		clock := newManualClock()
+		r := NewRateLimiter(RateLimiterConfig{Limit: 1, Interval: time.Second, Clock: clock})
+		var runs int32
+		fn := func(ctx context.Context) (interface{}, error) {
+			return atomic.AddInt32(&runs, 1), nil
+		}
+		_, err := r.DoContext(context.Background(), fn).Get()
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		doomed := r.DoContext(ctx, fn)
+		next := r.DoContext(context.Background(), fn)
+		cancel()
+		<-doomed.Done()
+
+		// When a new token is available
+		waitTimers(t, clock)
+		clock.Advance(time.Second)
+
+		// It is used by the next function in the queue
+		val, err := next.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), val)
+	})
+}
+
+func TestRateLimiter_DefaultExecutor(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a rate limiter that is set as the default executor
+		SetDefaultExecutor(NewRateLimiter(RateLimiterConfig{Limit: 1, Interval: time.Millisecond}))
+		defer SetDefaultExecutor(nil)
+
+		// The package functions run through it
+		f := DoProgress(func(report func(done, total int64)) (interface{}, error) {
+			report(1, 1)
+			return "ok", nil
+		})
+		_, ok := f.(QueuedFuture)
+		assert.True(t, ok)
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", val)
+	})
+}
@@ -0,0 +1,92 @@
+package manana
+
+// Recover returns a Future that succeeds with the same value as the argument future, or, if the
+// argument future fails, completes with the value or the error returned by the recovery function,
+// which receives the error of the failed future.
+//
+// If the argument future is canceled, the returned Future is canceled too, unless the
+// RecoverCanceled option is passed. In that case, the recovery function is invoked with
+// ErrorCanceled. Canceling the returned Future also cancels the argument future. If the recovery
+// function panics, the returned Future fails with a *PanicError.
+func Recover(future Future, recovery func(err error) (interface{}, error), options ...RecoverOption) Future {
+	p := recoveredPromise(future)
+	future.OnFail(func(err error) {
+		if err == ErrorCanceled && !hasOption(options, RecoverCanceled) {
+			p.Cancel()
+			return
+		}
+		if p.IsCompleted() {
+			return
+		}
+		value, rErr := safeCall(func() (interface{}, error) {
+			return recovery(err)
+		})
+		complete(p, value, rErr)
+	})
+	return p
+}
+
+// RecoverWith returns a Future that succeeds with the same value as the argument future, or, if
+// the argument future fails, completes with the same result as the fallback Future returned by
+// the recovery function, which receives the error of the failed future. It allows writing
+// fallback chains, e.g. main storage -> replica -> default value.
+//
+// Cancellation is handled as in the Recover function. Canceling the returned Future also cancels
+// the fallback Future, if the recovery function has been already invoked. If the recovery
+// function returns nil, the returned Future fails with ErrorNilFuture.
+func RecoverWith(future Future, recovery func(err error) Future, options ...RecoverOption) Future {
+	p := recoveredPromise(future)
+	future.OnFail(func(err error) {
+		if err == ErrorCanceled && !hasOption(options, RecoverCanceled) {
+			p.Cancel()
+			return
+		}
+		if p.IsCompleted() {
+			return
+		}
+		fallback, pErr := safeFuture(func() Future {
+			return recovery(err)
+		})
+		if pErr != nil {
+			p.Fail(pErr)
+			return
+		}
+		fallback.OnComplete(func(value interface{}, err error) {
+			complete(p, value, err)
+		})
+		p.OnFail(func(err error) {
+			if err == ErrorCanceled {
+				fallback.Cancel()
+			}
+		})
+	})
+	return p
+}
+
+// MapError returns a Future that succeeds with the same value as the argument future, or, if the
+// argument future fails, fails with the error returned by the mapper function, which receives
+// the original error. It is useful to wrap or translate errors. If the mapper function returns
+// nil, the returned Future succeeds with a nil value.
+//
+// Cancellation is handled as in the Recover function.
+func MapError(future Future, mapper func(err error) error, options ...RecoverOption) Future {
+	return Recover(future, func(err error) (interface{}, error) {
+		return nil, mapper(err)
+	}, options...)
+}
+
+// recoveredPromise returns a new Promise that succeeds when the source future succeeds. Canceling
+// the returned Promise also cancels the source. Handling the failure of the source is left to
+// the invoker.
+func recoveredPromise(source Future) Promise {
+	p := NewPromise()
+	source.OnSuccess(func(value interface{}) {
+		p.Success(value)
+	})
+	p.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			source.Cancel()
+		}
+	})
+	return p
+}
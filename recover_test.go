@@ -0,0 +1,187 @@
+package manana
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover_Success(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that succeeds
+		f := Do(func() (interface{}, error) {
+			return "hi!", nil
+		})
+
+		// When a recovery function is attached
+		invoked := false
+		r := Recover(f, func(err error) (interface{}, error) {
+			invoked = true
+			return "recovered", nil
+		})
+
+		// The success value is kept and the recovery function is not invoked
+		val, err := r.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "hi!", val)
+		assert.False(t, invoked)
+	}))
+}
+
+func TestRecover_Error(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that fails
+		f := Do(func() (interface{}, error) {
+			return nil, errors.New("catapun")
+		})
+
+		// When a recovery function is attached
+		r := Recover(f, func(err error) (interface{}, error) {
+			return "recovered from " + err.Error(), nil
+		})
+
+		// The returned future succeeds with the recovered value
+		val, err := r.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "recovered from catapun", val)
+	}))
+}
+
+func TestRecoverWith_FallbackChain(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a primary storage and a replica that fail
+		primary := Do(func() (interface{}, error) {
+			return nil, errors.New("primary is down")
+		})
+		replica := func(err error) Future {
+			return Do(func() (interface{}, error) {
+				return nil, errors.New("replica is down")
+			})
+		}
+
+		// When building a fallback chain primary -> replica -> default value
+		var errs []error
+		r := Recover(RecoverWith(primary, func(err error) Future {
+			errs = append(errs, err)
+			return replica(err)
+		}), func(err error) (interface{}, error) {
+			errs = append(errs, err)
+			return "default", nil
+		})
+
+		// The default value is returned after trying all the fallbacks in order
+		val, err := r.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "default", val)
+		assert.Len(t, errs, 2)
+		assert.EqualError(t, errs[0], "primary is down")
+		assert.EqualError(t, errs[1], "replica is down")
+	}))
+}
+
+func TestRecoverWith_NilFuture(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a recovery function that returns a nil future
+		r := RecoverWith(Do(func() (interface{}, error) {
+			return nil, errors.New("catapun")
+		}), func(err error) Future {
+			return nil
+		})
+
+		// The returned future fails
+		_, err := r.Get()
+		assert.Equal(t, ErrorNilFuture, err)
+	}))
+}
+
+func TestMapError(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that fails
+		cause := errors.New("catapun")
+		f := Do(func() (interface{}, error) {
+			return nil, cause
+		})
+
+		// When its error is wrapped
+		r := MapError(f, func(err error) error {
+			return fmt.Errorf("loading data: %w", err)
+		})
+
+		// The returned future fails with the wrapped error
+		_, err := r.Get()
+		assert.EqualError(t, err, "loading data: catapun")
+		assert.True(t, errors.Is(err, cause))
+	}))
+}
+
+func TestRecover_CanceledIsNotRecovered(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future
+		f := NewPromise()
+
+		// With recovery functions
+		invoked := false
+		r := Recover(f, func(err error) (interface{}, error) {
+			invoked = true
+			return "recovered", nil
+		})
+		m := MapError(r, func(err error) error {
+			invoked = true
+			return err
+		})
+
+		// When the future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// The returned futures are also canceled
+		_, err := m.Get()
+		assert.Equal(t, ErrorCanceled, err)
+		assert.True(t, r.IsCanceled())
+		assert.True(t, m.IsCanceled())
+		// Without invoking the recovery functions
+		assert.False(t, invoked)
+	}))
+}
+
+func TestRecover_RecoverCanceled(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future
+		f := NewPromise()
+
+		// With a recovery function that explicitly handles cancellation
+		r := Recover(f, func(err error) (interface{}, error) {
+			assert.Equal(t, ErrorCanceled, err)
+			return "recovered", nil
+		}, RecoverCanceled)
+
+		// When the future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// The returned future is recovered
+		val, err := r.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "recovered", val)
+	}))
+}
+
+func TestRecover_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a recovered future
+		f := NewPromise()
+		r := RecoverWith(f, func(err error) Future {
+			return Do(func() (interface{}, error) {
+				return "fallback", nil
+			})
+		})
+
+		// When the recovered future is canceled
+		assert.NoError(t, r.Cancel())
+
+		// The source future is canceled too
+		<-f.CancelCtx()
+		assert.True(t, f.IsCanceled())
+	}))
+}
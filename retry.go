@@ -0,0 +1,150 @@
+package manana
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes the time to wait between the attempts of a retried function.
+type Backoff interface {
+	// Delay returns the time to wait after the given failed attempt, starting at 1.
+	Delay(attempt int) time.Duration
+}
+
+// ConstantBackoff is a Backoff that always waits the same time between attempts.
+type ConstantBackoff time.Duration
+
+// Delay returns the constant backoff duration.
+func (b ConstantBackoff) Delay(_ int) time.Duration {
+	return time.Duration(b)
+}
+
+// ExponentialBackoff is a Backoff whose delay grows exponentially with the number of attempts.
+type ExponentialBackoff struct {
+	// Initial is the delay after the first failed attempt
+	Initial time.Duration
+	// Multiplier is the factor the delay is multiplied by after each attempt. If it is lower or
+	// equal than 1, the delay is doubled after each attempt.
+	Multiplier float64
+	// Max is the maximum delay. If it is zero, the delay is not limited.
+	Max time.Duration
+}
+
+// Delay returns Initial * Multiplier^(attempt-1), limited to the Max delay.
+func (b ExponentialBackoff) Delay(attempt int) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(delay)
+}
+
+// Jitter returns a Backoff that randomizes the delays of the argument Backoff, to avoid many
+// clients retrying at the same time. The factor, between 0 and 1, specifies the fraction of the
+// delay that is randomized: a delay d is converted to a random value between d*(1-factor) and d.
+func Jitter(backoff Backoff, factor float64) Backoff {
+	return jitterBackoff{backoff: backoff, factor: math.Max(0, math.Min(1, factor))}
+}
+
+type jitterBackoff struct {
+	backoff Backoff
+	factor  float64
+}
+
+func (b jitterBackoff) Delay(attempt int) time.Duration {
+	delay := float64(b.backoff.Delay(attempt))
+	return time.Duration(delay * (1 - b.factor*rand.Float64()))
+}
+
+// RetryPolicy specifies how a function is retried by the Retry function.
+type RetryPolicy struct {
+	// Backoff specifies the time to wait between attempts. If nil, the attempts are not delayed.
+	Backoff Backoff
+	// MaxAttempts is the maximum number of attempts, including the first one. If it is zero, the
+	// number of attempts is only limited by MaxElapsed. If both are zero, the function is attempted
+	// at most 3 times.
+	MaxAttempts int
+	// MaxElapsed is the maximum time since the first attempt after which no more attempts are
+	// started. If it is zero, the elapsed time is not limited.
+	MaxElapsed time.Duration
+	// Retryable decides whether a failed attempt should be retried, according to its error. If
+	// nil, all errors are retried. ErrorCanceled is never retried.
+	Retryable func(err error) bool
+}
+
+// retryErrorsKept is the maximum number of errors kept in a RetryError: the errors of the first
+// and the last attempts, half and half
+const retryErrorsKept = 10
+
+// RetryError is the error of a Future returned by Retry when all its attempts failed. The embedded
+// AggregateError contains the errors of the failed attempts, in order. If there are many attempts,
+// only the errors of the first 5 and the last 5 attempts are kept.
+type RetryError struct {
+	AggregateError
+	// Attempts is the number of failed attempts, which may be larger than the number of Errors
+	Attempts int
+}
+
+func (e *RetryError) Error() string {
+	if e.Attempts <= len(e.Errors) {
+		return fmt.Sprintf("failed after %d attempts: [%s]", e.Attempts, joinErrors(e.Errors))
+	}
+	half := len(e.Errors) / 2
+	return fmt.Sprintf("failed after %d attempts: [%s; ... (%d more); %s]", e.Attempts,
+		joinErrors(e.Errors[:half]), e.Attempts-len(e.Errors), joinErrors(e.Errors[half:]))
+}
+
+// Retry runs a cancelable function in background, as DoCtx does, retrying it according to the
+// passed policy while it fails. The function receives the number of the current attempt, starting
+// at 1, and the cancel channel of the returned Future.
+//
+// The returned Future succeeds with the value of the first successful attempt, or fails with a
+// *RetryError that contains the errors of the attempts. Canceling the returned Future stops the
+// retry loop immediately, even if it is waiting between attempts.
+func Retry(policy RetryPolicy, fn func(attempt int, cancel <-chan struct{}) (interface{}, error)) Future {
+	if policy.MaxAttempts <= 0 && policy.MaxElapsed <= 0 {
+		policy.MaxAttempts = 3
+	}
+	return DoCtx(func(cancel <-chan struct{}) (interface{}, error) {
+		start := time.Now()
+		var errs []error
+		attempt := 1
+		for ; ; attempt++ {
+			value, err := fn(attempt, cancel)
+			if err == nil {
+				return value, nil
+			}
+			errs = append(errs, err)
+			if len(errs) > retryErrorsKept {
+				// discarding the oldest error after the first attempts
+				errs = append(errs[:retryErrorsKept/2], errs[retryErrorsKept/2+1:]...)
+			}
+			if err == ErrorCanceled ||
+				(policy.Retryable != nil && !policy.Retryable(err)) ||
+				(policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts) {
+				break
+			}
+			var delay time.Duration
+			if policy.Backoff != nil {
+				delay = policy.Backoff.Delay(attempt)
+			}
+			if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+				break
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-cancel:
+				timer.Stop()
+				return nil, ErrorCanceled
+			}
+		}
+		return nil, &RetryError{AggregateError: AggregateError{Errors: errs}, Attempts: attempt}
+	})
+}
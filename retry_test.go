@@ -0,0 +1,171 @@
+package manana
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry_EventualSuccess(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a flaky function that fails twice
+		var attempts []int
+		fn := func(attempt int, _ <-chan struct{}) (interface{}, error) {
+			attempts = append(attempts, attempt)
+			if attempt < 3 {
+				return nil, fmt.Errorf("attempt %d failed", attempt)
+			}
+			return "success", nil
+		}
+
+		// When it is retried
+		f := Retry(RetryPolicy{Backoff: ConstantBackoff(time.Millisecond), MaxAttempts: 5}, fn)
+
+		// The value of the first successful attempt is returned
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "success", val)
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+	}))
+}
+
+func TestRetry_MaxAttempts(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that always fails
+		fn := func(attempt int, _ <-chan struct{}) (interface{}, error) {
+			return nil, fmt.Errorf("attempt %d failed", attempt)
+		}
+
+		// When it is retried a limited number of times
+		_, err := Retry(RetryPolicy{MaxAttempts: 3}, fn).Get()
+
+		// The future fails with the errors of all the attempts
+		assert.IsType(t, &RetryError{}, err)
+		assert.EqualError(t, err,
+			"failed after 3 attempts: [attempt 1 failed; attempt 2 failed; attempt 3 failed]")
+		assert.Len(t, err.(*RetryError).Errors, 3)
+	}))
+}
+
+func TestRetry_ManyAttempts(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that always fails
+		fn := func(attempt int, _ <-chan struct{}) (interface{}, error) {
+			return nil, fmt.Errorf("%d", attempt)
+		}
+
+		// When it is retried many times
+		_, err := Retry(RetryPolicy{MaxAttempts: 100}, fn).Get()
+
+		// Only the errors of the first and the last attempts are kept
+		assert.EqualError(t, err, "failed after 100 attempts: [1; 2; 3; 4; 5; ... (90 more); 96; 97; 98; 99; 100]")
+		assert.Len(t, err.(*RetryError).Errors, 10)
+		assert.Equal(t, 100, err.(*RetryError).Attempts)
+	}))
+}
+
+func TestRetry_DefaultPolicy(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that always fails
+		attempts := 0
+		fn := func(_ int, _ <-chan struct{}) (interface{}, error) {
+			attempts++
+			return nil, errors.New("catapun")
+		}
+
+		// When it is retried with the zero policy
+		_, err := Retry(RetryPolicy{}, fn).Get()
+
+		// The number of attempts is limited
+		assert.Error(t, err)
+		assert.Equal(t, 3, attempts)
+	}))
+}
+
+func TestRetry_NotRetryable(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that fails with a permanent error
+		permanent := errors.New("not found")
+		attempts := 0
+		fn := func(_ int, _ <-chan struct{}) (interface{}, error) {
+			attempts++
+			return nil, permanent
+		}
+
+		// When it is retried only for temporary errors
+		_, err := Retry(RetryPolicy{
+			MaxAttempts: 5,
+			Retryable: func(err error) bool {
+				return err != permanent
+			},
+		}, fn).Get()
+
+		// It is not retried
+		assert.True(t, errors.Is(err, permanent))
+		assert.Equal(t, 1, attempts)
+	}))
+}
+
+func TestRetry_MaxElapsed(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that always fails
+		attempts := 0
+		fn := func(_ int, _ <-chan struct{}) (interface{}, error) {
+			attempts++
+			return nil, errors.New("catapun")
+		}
+
+		// When it is retried for a limited time
+		_, err := Retry(RetryPolicy{
+			Backoff:    ConstantBackoff(20 * time.Millisecond),
+			MaxElapsed: 50 * time.Millisecond,
+		}, fn).Get()
+
+		// The retries stop when the time is exceeded
+		assert.IsType(t, &RetryError{}, err)
+		assert.True(t, attempts >= 2 && attempts <= 3, attempts)
+	}))
+}
+
+func TestRetry_Cancel(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a function that is retried with long waits between attempts
+		failed := make(chan struct{})
+		f := Retry(RetryPolicy{Backoff: ConstantBackoff(time.Hour)},
+			func(_ int, _ <-chan struct{}) (interface{}, error) {
+				close(failed)
+				return nil, errors.New("catapun")
+			})
+		<-failed
+
+		// When the future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// The retry loop stops immediately
+		_, err := f.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff{Initial: 10 * time.Millisecond, Multiplier: 3, Max: time.Second}
+	assert.Equal(t, 10*time.Millisecond, b.Delay(1))
+	assert.Equal(t, 30*time.Millisecond, b.Delay(2))
+	assert.Equal(t, 90*time.Millisecond, b.Delay(3))
+	assert.Equal(t, time.Second, b.Delay(10))
+
+	// default multiplier doubles the delay
+	b = ExponentialBackoff{Initial: time.Millisecond}
+	assert.Equal(t, 8*time.Millisecond, b.Delay(4))
+}
+
+func TestJitter(t *testing.T) {
+	b := Jitter(ConstantBackoff(100*time.Millisecond), 0.5)
+	for i := 1; i < 100; i++ {
+		d := b.Delay(i)
+		assert.True(t, d >= 50*time.Millisecond && d <= 100*time.Millisecond, d)
+	}
+}
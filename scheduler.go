@@ -0,0 +1,179 @@
+package manana
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Scheduler runs cancelable functions at given instants. The pending functions are kept in a timer
+// heap that is served by a single timer, so scheduling many functions does not create a goroutine
+// or a timer for each one.
+//
+// Canceling a Future returned by a Scheduler before its instant is reached removes its function
+// from the heap, so it never starts. Canceling it once it is running closes its cancel channel, as
+// in the DoCtx function.
+type Scheduler struct {
+	executor Executor
+	mutex    sync.Mutex
+	tasks    taskHeap
+	timer    *time.Timer
+}
+
+var defaultScheduler = NewScheduler(nil)
+
+// NewScheduler creates a Scheduler that runs the functions in the passed Executor when their
+// instant is reached. If the executor is nil, the functions run in the default Executor.
+func NewScheduler(executor Executor) *Scheduler {
+	return &Scheduler{executor: executor}
+}
+
+// After runs a cancelable function in background after the given delay, unless the returned
+// Future is canceled before. It uses a Scheduler that is shared by the whole application.
+func After(delay time.Duration, fn func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	return defaultScheduler.After(delay, fn)
+}
+
+// At runs a cancelable function in background at the given instant, unless the returned Future is
+// canceled before. It uses a Scheduler that is shared by the whole application.
+func At(instant time.Time, fn func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	return defaultScheduler.At(instant, fn)
+}
+
+// After runs a cancelable function in background after the given delay, unless the returned
+// Future is canceled before.
+func (s *Scheduler) After(delay time.Duration, fn func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	return s.At(time.Now().Add(delay), fn)
+}
+
+// At runs a cancelable function in background at the given instant, unless the returned Future is
+// canceled before. If the instant is in the past, the function runs as soon as possible.
+func (s *Scheduler) At(instant time.Time, fn func(cancelCtx <-chan struct{}) (interface{}, error)) Future {
+	task := &scheduledTask{at: instant, fn: fn, promise: NewPromise()}
+	s.mutex.Lock()
+	heap.Push(&s.tasks, task)
+	s.resetTimer()
+	s.mutex.Unlock()
+	task.promise.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			s.cancel(task)
+		}
+	})
+	return task.promise
+}
+
+// Pending returns the number of functions that are waiting for their instant to be reached.
+func (s *Scheduler) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.tasks)
+}
+
+// resetTimer arms the timer for the earliest pending task. It must be invoked with the mutex locked.
+func (s *Scheduler) resetTimer() {
+	if len(s.tasks) == 0 {
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		return
+	}
+	delay := time.Until(s.tasks[0].at)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(delay, s.fire)
+	} else {
+		s.timer.Stop()
+		s.timer.Reset(delay)
+	}
+}
+
+// fire starts all the tasks whose instant has been reached
+func (s *Scheduler) fire() {
+	var due []*scheduledTask
+	now := time.Now()
+	s.mutex.Lock()
+	for len(s.tasks) > 0 && !s.tasks[0].at.After(now) {
+		due = append(due, heap.Pop(&s.tasks).(*scheduledTask))
+	}
+	s.resetTimer()
+	s.mutex.Unlock()
+	for _, task := range due {
+		s.start(task)
+	}
+}
+
+func (s *Scheduler) start(task *scheduledTask) {
+	if task.promise.IsCompleted() {
+		return
+	}
+	executor := s.executor
+	if executor == nil {
+		executor = DefaultExecutor()
+	}
+	running := executor.DoCtx(task.fn)
+	running.OnComplete(func(value interface{}, err error) {
+		complete(task.promise, value, err)
+	})
+	s.mutex.Lock()
+	task.running = running
+	s.mutex.Unlock()
+	// the promise could have been canceled before the running future was stored
+	if task.promise.IsCanceled() {
+		running.Cancel()
+	}
+}
+
+// cancel removes a task from the heap, or cancels its function if it is already running
+func (s *Scheduler) cancel(task *scheduledTask) {
+	s.mutex.Lock()
+	if task.index >= 0 {
+		heap.Remove(&s.tasks, task.index)
+		s.resetTimer()
+	}
+	running := task.running
+	s.mutex.Unlock()
+	if running != nil {
+		running.Cancel()
+	}
+}
+
+type scheduledTask struct {
+	at      time.Time
+	fn      func(cancelCtx <-chan struct{}) (interface{}, error)
+	promise Promise
+	// running is the Future of the function, once it has been started
+	running Future
+	// index of the task in the heap, or -1 if it is not in the heap
+	index int
+}
+
+// taskHeap implements heap.Interface, sorting the tasks by their instant
+type taskHeap []*scheduledTask
+
+func (h taskHeap) Len() int {
+	return len(h)
+}
+
+func (h taskHeap) Less(i, j int) bool {
+	return h[i].at.Before(h[j].at)
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	task := x.(*scheduledTask)
+	task.index = len(*h)
+	*h = append(*h, task)
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	task := old[len(old)-1]
+	old[len(old)-1] = nil
+	task.index = -1
+	*h = old[:len(old)-1]
+	return task
+}
@@ -0,0 +1,121 @@
+package manana
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAfter(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that is scheduled after a delay
+		start := time.Now()
+		f := After(30*time.Millisecond, func(_ <-chan struct{}) (interface{}, error) {
+			return time.Since(start), nil
+		})
+
+		// It does not run before the delay
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.True(t, val.(time.Duration) >= 30*time.Millisecond, val)
+	}))
+}
+
+func TestAt_Past(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a function that is scheduled at an instant in the past
+		f := At(time.Now().Add(-time.Hour), func(_ <-chan struct{}) (interface{}, error) {
+			return "hello", nil
+		})
+
+		// It runs as soon as possible
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", val)
+	}))
+}
+
+func TestScheduler_Order(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a set of functions scheduled in a different order than their instants, in a
+		// scheduler whose executor runs them synchronously, in the order they are started
+		s := NewScheduler(submitter(func(task func()) error {
+			task()
+			return nil
+		}))
+		var mutex sync.Mutex
+		var order []int
+		record := func(n int) func(_ <-chan struct{}) (interface{}, error) {
+			return func(_ <-chan struct{}) (interface{}, error) {
+				mutex.Lock()
+				order = append(order, n)
+				mutex.Unlock()
+				return n, nil
+			}
+		}
+		now := time.Now()
+		f3 := s.At(now.Add(60*time.Millisecond), record(3))
+		f1 := s.At(now.Add(20*time.Millisecond), record(1))
+		f2 := s.At(now.Add(40*time.Millisecond), record(2))
+
+		// They are started in the order of their instants
+		_, err := All(f1, f2, f3).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, order)
+		assert.Equal(t, 0, s.Pending())
+	}))
+}
+
+func TestScheduler_CancelPending(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a set of scheduled functions
+		s := NewScheduler(nil)
+		run := make(chan int, 3)
+		schedule := func(n int, delay time.Duration) Future {
+			return s.After(delay, func(_ <-chan struct{}) (interface{}, error) {
+				run <- n
+				return n, nil
+			})
+		}
+		f1 := schedule(1, time.Hour)
+		f2 := schedule(2, 20*time.Millisecond)
+		f3 := schedule(3, 30*time.Millisecond)
+		assert.Equal(t, 3, s.Pending())
+
+		// When some of them are canceled before starting
+		assert.NoError(t, f1.Cancel())
+		assert.NoError(t, f2.Cancel())
+
+		// They are removed from the scheduler and never run
+		_, err := f3.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, 3, <-run)
+		assert.Equal(t, 0, s.Pending())
+		assert.Empty(t, run)
+	})
+}
+
+func TestScheduler_CancelRunning(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a scheduled function that has started
+		started := make(chan struct{})
+		interrupted := make(chan struct{})
+		f := After(0, func(cancelCtx <-chan struct{}) (interface{}, error) {
+			close(started)
+			<-cancelCtx
+			close(interrupted)
+			return nil, ErrorCanceled
+		})
+		<-started
+
+		// When its future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// The running function is notified
+		<-interrupted
+		_, err := f.Get()
+		assert.Equal(t, ErrorCanceled, err)
+	})
+}
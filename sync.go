@@ -1,46 +1,250 @@
 package manana
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrorNotEnoughFutures is the error of the futures returned by Some and Any when they receive
+// fewer argument futures than the required number of successes.
+var ErrorNotEnoughFutures = errors.New("there are fewer futures than the required successes")
 
 // All returns a future that will succeed when all the argument futures suceed. If only one of the
 // futures fail, the returned future will fail with the error of the first failed future.
 // If the returned future succeeds, the return value is an array containing the success values of
 // all the parameter futures, in the same order as they are passed to the All function.
-// TODO: test and correct, if apply
-// todo: crear un tipo de "joinfuture" que facilite el uso de arrays y cancele en arbol
+//
+// When the returned future fails or is canceled, the argument futures that are still running
+// are canceled. If any of the argument futures is canceled, the returned future is canceled too.
+//
+// The progress of the returned future is the sum of the progress of the argument futures, so
+// each future is weighted by its total units of work.
 func All(futures ...Future) Future {
-	var wg sync.WaitGroup
-	wg.Add(len(futures))
+	allFuture := NewPromise()
 	results := make([]interface{}, len(futures))
+	if len(futures) == 0 {
+		allFuture.Success(results)
+		return allFuture
+	}
 
-	allFuture := NewPromise()
+	var mutex sync.Mutex
+	pending := len(futures)
+	for i, f := range futures {
+		index := i
+		f.OnComplete(func(value interface{}, err error) {
+			if err != nil {
+				complete(allFuture, nil, err)
+				return
+			}
+			mutex.Lock()
+			results[index] = value
+			pending--
+			finished := pending == 0
+			mutex.Unlock()
+			if finished {
+				allFuture.Success(results)
+			}
+		})
+	}
+	cancelOnFail(allFuture, futures)
+	sumProgress(allFuture, futures)
+
+	return allFuture
+}
+
+// Result holds the outcome of a completed Future: the value resulting from a successful
+// operation, or the error resulting from a failed operation (or ErrorCanceled).
+type Result struct {
+	Value interface{}
+	Err   error
+}
 
+// AggregateError groups the errors of multiple failed futures.
+type AggregateError struct {
+	// Errors of the failed futures
+	Errors []error
+}
+
+func (e *AggregateError) Error() string {
+	return fmt.Sprintf("%d futures failed: [%s]", len(e.Errors), joinErrors(e.Errors))
+}
+
+// joinErrors returns the messages of the errors, separated by semicolons
+func joinErrors(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the grouped errors, so they can be inspected with errors.Is and errors.As.
+func (e *AggregateError) Unwrap() []error {
+	return e.Errors
+}
+
+// Any returns a future that succeeds with the value of the first argument future that succeeds.
+// The returned future fails only if all the argument futures fail (or are canceled), with an
+// *AggregateError containing all their errors, in order of completion. If there are no argument
+// futures, the returned future fails with ErrorNotEnoughFutures. Canceling the returned future
+// cancels all the argument futures.
+//
+// Accepted options: CancelLosers.
+func Any(futures []Future, options ...SelectOption) Future {
+	return Map(Some(1, futures, options...), func(values interface{}) interface{} {
+		return values.([]interface{})[0]
+	})
+}
+
+// Race returns a future that completes with the same result as the first argument future that
+// completes, either successfully or not. If no futures are passed, the returned future never
+// completes. Canceling the returned future cancels all the argument futures.
+//
+// Accepted options: CancelLosers.
+func Race(futures []Future, options ...SelectOption) Future {
+	raceFuture := NewPromise()
 	for i, f := range futures {
-		future := f
 		index := i
-		go func() {
-			defer wg.Done()
-			val, err := future.Get()
+		f.OnComplete(func(value interface{}, err error) {
+			if complete(raceFuture, value, err) == nil && hasOption(options, CancelLosers) {
+				cancelAllBut(futures, index)
+			}
+		})
+	}
+	cancelOnCancel(raceFuture, futures)
+	return raceFuture
+}
+
+// AllSettled returns a future that waits for the completion of all the argument futures, and
+// then succeeds with a []Result slice containing the value or the error of each future, in the
+// same order as they are passed to the AllSettled function. The returned future never fails.
+// Canceling the returned future cancels all the argument futures.
+func AllSettled(futures ...Future) Future {
+	settledFuture := NewPromise()
+	results := make([]Result, len(futures))
+	if len(futures) == 0 {
+		settledFuture.Success(results)
+		return settledFuture
+	}
+
+	var mutex sync.Mutex
+	pending := len(futures)
+	for i, f := range futures {
+		index := i
+		f.OnComplete(func(value interface{}, err error) {
+			mutex.Lock()
+			results[index] = Result{Value: value, Err: err}
+			pending--
+			finished := pending == 0
+			mutex.Unlock()
+			if finished {
+				settledFuture.Success(results)
+			}
+		})
+	}
+	cancelOnCancel(settledFuture, futures)
+	return settledFuture
+}
+
+// Some returns a future that succeeds when a quorum of n argument futures succeed. The success
+// value is a []interface{} slice with the values of the first n futures that succeeded, in order
+// of completion. The returned future fails as soon as the quorum can't be reached, with an
+// *AggregateError containing the errors of the failed futures, in order of completion. Canceled
+// argument futures are considered as failed with ErrorCanceled. If n is larger than the number of
+// argument futures, the returned future immediately fails with ErrorNotEnoughFutures.
+//
+// When the returned future fails or is canceled, the argument futures that are still running
+// are canceled.
+//
+// Accepted options: CancelLosers.
+func Some(n int, futures []Future, options ...SelectOption) Future {
+	someFuture := NewPromise()
+	if n <= 0 {
+		someFuture.Success([]interface{}{})
+		return someFuture
+	}
+	if n > len(futures) {
+		someFuture.Fail(ErrorNotEnoughFutures)
+		cancelAllBut(futures, -1)
+		return someFuture
+	}
+	var mutex sync.Mutex
+	values := make([]interface{}, 0, n)
+	var errs []error
+	done := false
+	for _, f := range futures {
+		f.OnComplete(func(value interface{}, err error) {
+			// the futures are completed and canceled outside the mutex, as their callbacks could run
+			// inline and get back here
+			mutex.Lock()
+			if done {
+				mutex.Unlock()
+				return
+			}
 			if err != nil {
-				allFuture.Fail(err)
-			}
-			results[index] = val
-		}()
-	}
-
-	go func() {
-		waitCh := make(chan interface{})
-		go func() {
-			wg.Wait()
-			close(waitCh)
-		}()
-		select {
-		case <-waitCh:
-			allFuture.Success(results)
-		case <-allFuture.(*promiseImpl).context.Done(): // if cancelled
-			allFuture.Cancel()
+				errs = append(errs, err)
+				failed := len(errs) > len(futures)-n
+				done = failed
+				mutex.Unlock()
+				if failed {
+					someFuture.Fail(&AggregateError{Errors: errs})
+				}
+				return
+			}
+			values = append(values, value)
+			succeeded := len(values) == n
+			done = succeeded
+			mutex.Unlock()
+			if succeeded && someFuture.Success(values) == nil && hasOption(options, CancelLosers) {
+				cancelAllBut(futures, -1)
+			}
+		})
+	}
+	cancelOnFail(someFuture, futures)
+	return someFuture
+}
+
+// sumProgress reports, as the progress of the parent future, the sum of the progress of all the
+// children futures.
+func sumProgress(parent Promise, children []Future) {
+	for _, c := range children {
+		c.OnProgress(func(_ Progress) {
+			var sum Progress
+			for _, child := range children {
+				p := child.Progress()
+				sum.Done += p.Done
+				sum.Total += p.Total
+			}
+			parent.ReportProgress(sum.Done, sum.Total)
+		}, 0)
+	}
+}
+
+// cancelOnFail cancels the children futures that are still running when the parent future fails
+// or is canceled.
+func cancelOnFail(parent Future, children []Future) {
+	parent.OnFail(func(_ error) {
+		cancelAllBut(children, -1)
+	})
+}
+
+// cancelOnCancel cancels the children futures that are still running when the parent future is
+// canceled.
+func cancelOnCancel(parent Future, children []Future) {
+	parent.OnFail(func(err error) {
+		if err == ErrorCanceled {
+			cancelAllBut(children, -1)
 		}
-	}()
+	})
+}
 
-	return allFuture
+// cancelAllBut cancels all the futures but the one at the given index
+func cancelAllBut(futures []Future, index int) {
+	for i, f := range futures {
+		if i != index {
+			f.Cancel()
+		}
+	}
 }
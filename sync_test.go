@@ -0,0 +1,312 @@
+package manana
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// after returns a future that completes with the given result after a delay
+func after(delay time.Duration, value interface{}, err error) Future {
+	return DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+		select {
+		case <-time.After(delay):
+			return value, err
+		case <-cancelCtx:
+			return nil, ErrorCanceled
+		}
+	})
+}
+
+func TestAny_FirstSuccess(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures where the fastest one fails
+		futures := []Future{
+			after(10*time.Millisecond, nil, errors.New("catapun")),
+			after(50*time.Millisecond, "second", nil),
+			after(time.Second, "third", nil),
+		}
+
+		// When waiting for any of them
+		val, err := Any(futures).Get()
+
+		// The value of the first successful future is returned
+		assert.NoError(t, err)
+		assert.Equal(t, "second", val)
+		// And the losers are not canceled by default
+		assert.False(t, futures[2].IsCompleted())
+	}))
+}
+
+func TestAny_AllFail(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures that fail
+		first := errors.New("first")
+		second := errors.New("second")
+		futures := []Future{
+			after(50*time.Millisecond, nil, second),
+			after(10*time.Millisecond, nil, first),
+		}
+
+		// When waiting for any of them
+		_, err := Any(futures).Get()
+
+		// The returned future fails with all the errors
+		assert.IsType(t, &AggregateError{}, err)
+		assert.Len(t, err.(*AggregateError).Errors, 2)
+		assert.True(t, errors.Is(err, first))
+		assert.True(t, errors.Is(err, second))
+	}))
+}
+
+func TestAny_CancelLosers(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures
+		futures := []Future{
+			after(10*time.Millisecond, "first", nil),
+			after(time.Minute, "second", nil),
+		}
+
+		// When waiting for any of them, canceling the losers
+		val, err := Any(futures, CancelLosers).Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "first", val)
+
+		// The loser future is canceled
+		_, err = futures[1].Get()
+		assert.Equal(t, ErrorCanceled, err)
+	}))
+}
+
+func TestRace(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures where the fastest one fails
+		futures := []Future{
+			after(50*time.Millisecond, "slow", nil),
+			after(10*time.Millisecond, nil, errors.New("catapun")),
+		}
+
+		// When racing them
+		_, err := Race(futures, CancelLosers).Get()
+
+		// The result of the first completed future is returned
+		assert.EqualError(t, err, "catapun")
+
+		// And the loser is canceled
+		_, err = futures[0].Get()
+		assert.Equal(t, ErrorCanceled, err)
+	}))
+}
+
+func TestAllSettled(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures that succeed and fail
+		canceled := NewPromise()
+		canceled.Cancel()
+
+		// When waiting for all of them to settle
+		val, err := AllSettled(
+			after(20*time.Millisecond, "first", nil),
+			after(10*time.Millisecond, nil, errors.New("catapun")),
+			canceled,
+		).Get()
+
+		// The result of each future is returned in order
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{
+			{Value: "first"},
+			{Err: errors.New("catapun")},
+			{Err: ErrorCanceled},
+		}, val)
+	}))
+}
+
+func TestAllSettled_Empty(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		val, err := AllSettled().Get()
+		assert.NoError(t, err)
+		assert.Empty(t, val)
+	}))
+}
+
+func TestSome_Quorum(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures whose callbacks run inline, so they complete in a known order
+		promises := make([]Promise, 5)
+		futures := make([]Future, 5)
+		for i := range promises {
+			promises[i] = NewPromiseWithDispatcher(Inline)
+			futures[i] = promises[i]
+		}
+
+		// When waiting for a quorum of 3 successes
+		some := Some(3, futures, CancelLosers)
+		assert.NoError(t, promises[1].Success("first"))
+		assert.NoError(t, promises[2].Fail(errors.New("catapun")))
+		assert.NoError(t, promises[3].Success("second"))
+		assert.NoError(t, promises[0].Success("third"))
+		val, err := some.Get()
+
+		// The first successful values are returned in order of completion
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"first", "second", "third"}, val)
+
+		// And the pending futures are canceled
+		_, err = futures[4].Get()
+		assert.Equal(t, ErrorCanceled, err)
+	}))
+}
+
+func TestSome_QuorumNotReached(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures where too many of them fail
+		futures := []Future{
+			after(10*time.Millisecond, nil, errors.New("first")),
+			after(20*time.Millisecond, nil, errors.New("second")),
+			after(time.Minute, "never", nil),
+		}
+
+		// When waiting for a quorum of 2 successes
+		_, err := Some(2, futures).Get()
+
+		// The future fails as soon as the quorum can't be reached
+		assert.EqualError(t, err, "2 futures failed: [first; second]")
+		futures[2].Cancel()
+	}))
+}
+
+func TestSome_NotEnoughFutures(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given fewer futures than the required quorum
+		futures := []Future{NewPromise(), NewPromise()}
+
+		// When waiting for a quorum of 3 successes
+		_, err := Some(3, futures).Get()
+
+		// The future fails immediately
+		assert.Equal(t, ErrorNotEnoughFutures, err)
+
+		// And the argument futures are canceled
+		for _, f := range futures {
+			assert.True(t, f.IsCanceled())
+		}
+	}))
+}
+
+func TestAny_NoFutures(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		_, err := Any(nil).Get()
+		assert.Equal(t, ErrorNotEnoughFutures, err)
+	}))
+}
+
+func TestAll_Success(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures
+		futures := []Future{
+			after(30*time.Millisecond, "first", nil),
+			after(10*time.Millisecond, "second", nil),
+			after(20*time.Millisecond, "third", nil),
+		}
+
+		// When waiting for all of them
+		val, err := All(futures...).Get()
+
+		// The values are returned in the same order as the futures
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"first", "second", "third"}, val)
+	}))
+}
+
+func TestAll_Empty(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		val, err := All().Get()
+		assert.NoError(t, err)
+		assert.Empty(t, val)
+	}))
+}
+
+func TestAll_FailFast(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some futures where one of them fails while the others are still running
+		futures := []Future{
+			after(time.Minute, "first", nil),
+			after(10*time.Millisecond, nil, errors.New("catapun")),
+			after(time.Minute, "third", nil),
+		}
+
+		// When waiting for all of them
+		_, err := All(futures...).Get()
+
+		// The returned future fails without waiting for the others
+		assert.EqualError(t, err, "catapun")
+
+		// And the siblings are canceled
+		_, err = futures[0].Get()
+		assert.Equal(t, ErrorCanceled, err)
+		_, err = futures[2].Get()
+		assert.Equal(t, ErrorCanceled, err)
+	}))
+}
+
+func TestAll_ChildCanceled(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a composite future
+		futures := []Future{NewPromise(), NewPromise()}
+		all := All(futures...)
+
+		// When one of its children is canceled
+		assert.NoError(t, futures[0].Cancel())
+
+		// The cancellation is reported back to the composite future
+		_, err := all.Get()
+		assert.Equal(t, ErrorCanceled, err)
+		assert.True(t, all.IsCanceled())
+		// Which cancels the rest of children
+		<-futures[1].(Promise).CancelCtx()
+	}))
+}
+
+func TestCancel_Tree(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a tree of composite futures
+		leaves := []Promise{NewPromise(), NewPromise(), NewPromise(), NewPromise(), NewPromise()}
+		root := Then(
+			All(
+				AllSettled(leaves[0], leaves[1]),
+				Race([]Future{leaves[2]}),
+				Any([]Future{leaves[3], leaves[4]}),
+			),
+			func(value interface{}) (interface{}, error) {
+				return value, nil
+			})
+
+		// When the root of the tree is canceled
+		assert.NoError(t, root.Cancel())
+
+		// The cancellation cascades down to all the leaves
+		for _, leaf := range leaves {
+			<-leaf.CancelCtx()
+			assert.True(t, leaf.IsCanceled())
+		}
+	}))
+}
+
+func TestAllSettled_NotCanceledByChildren(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future waiting for some children to settle
+		children := []Future{NewPromise(), NewPromise()}
+		settled := AllSettled(children...)
+
+		// When one of the children is canceled
+		assert.NoError(t, children[0].Cancel())
+
+		// The cancellation is reported as a result, and the other child is not canceled
+		assert.NoError(t, children[1].(Promise).Success(1))
+		val, err := settled.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{Err: ErrorCanceled}, {Value: 1}}, val)
+	}))
+}
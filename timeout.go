@@ -0,0 +1,41 @@
+package manana
+
+import "time"
+
+// WithTimeout returns a Future that completes with the same result as the argument future, or
+// fails with ErrorTimeout if the argument future does not complete before the given timeout. In
+// that case, the argument future is canceled, so its CancelCtx channel is closed and the held work
+// can be interrupted.
+//
+// Canceling the returned Future also cancels the argument future.
+//
+// Accepted options: KeepRunning.
+func WithTimeout(future Future, timeout time.Duration, options ...TimeoutOption) Future {
+	return WithDeadline(future, time.Now().Add(timeout), options...)
+}
+
+// WithDeadline returns a Future that completes with the same result as the argument future, or
+// fails with ErrorTimeout if the argument future does not complete before the given deadline. In
+// that case, the argument future is canceled, as in the WithTimeout function.
+//
+// Canceling the returned Future also cancels the argument future.
+//
+// Accepted options: KeepRunning.
+func WithDeadline(future Future, deadline time.Time, options ...TimeoutOption) Future {
+	p := NewPromise()
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		if p.Fail(ErrorTimeout) == nil && !hasOption(options, KeepRunning) {
+			future.Cancel()
+		}
+	})
+	future.OnComplete(func(value interface{}, err error) {
+		complete(p, value, err)
+	})
+	p.OnComplete(func(_ interface{}, err error) {
+		timer.Stop()
+		if err == ErrorCanceled {
+			future.Cancel()
+		}
+	})
+	return p
+}
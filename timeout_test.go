@@ -0,0 +1,95 @@
+package manana
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTimeout_Completes(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that completes before the timeout
+		f := WithTimeout(after(10*time.Millisecond, "hello", nil), time.Second)
+
+		// Its result is returned
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", val)
+
+		// Also for failures
+		_, err = WithTimeout(after(0, nil, errors.New("catapun")), time.Second).Get()
+		assert.EqualError(t, err, "catapun")
+	}))
+}
+
+func TestWithTimeout_Timeout(t *testing.T) {
+	assertNoLeaks(t, func() {
+		// Given a future that takes longer than the timeout
+		interrupted := make(chan struct{})
+		source := DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+			<-cancelCtx
+			close(interrupted)
+			return nil, ErrorCanceled
+		})
+
+		// When the timeout is reached
+		_, err := WithTimeout(source, 20*time.Millisecond).Get()
+
+		// The returned future fails with a timeout error
+		assert.Equal(t, ErrorTimeout, err)
+		// And the source future is canceled
+		<-interrupted
+		assert.True(t, source.IsCanceled())
+	})
+}
+
+func TestWithTimeout_KeepRunning(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future that takes longer than the timeout
+		source := NewPromise()
+
+		// When the timeout is reached, but the source is kept running
+		_, err := WithTimeout(source, 10*time.Millisecond, KeepRunning).Get()
+		assert.Equal(t, ErrorTimeout, err)
+
+		// The source future is not canceled
+		assert.False(t, source.IsCompleted())
+		assert.NoError(t, source.Success(1))
+	}))
+}
+
+func TestWithDeadline_InPipeline(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a pipeline whose first step takes too long
+		source := NewPromise()
+		f := Recover(WithDeadline(source, time.Now().Add(10*time.Millisecond)),
+			func(err error) (interface{}, error) {
+				return "default", nil
+			})
+
+		// The timeout can be recovered as any other error
+		val, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, "default", val)
+
+		// And the source is eventually canceled
+		<-source.CancelCtx()
+		assert.True(t, source.IsCanceled())
+	}))
+}
+
+func TestWithTimeout_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a future with a timeout
+		source := NewPromise()
+		f := WithTimeout(source, time.Hour)
+
+		// When the returned future is canceled
+		assert.NoError(t, f.Cancel())
+
+		// The source is canceled too
+		<-source.CancelCtx()
+	}))
+}
@@ -0,0 +1,217 @@
+// Package typed provides a type-safe, generic version of the manana Future and Promise
+// interfaces. Typed futures are backed by regular manana futures, so both APIs can be mixed while
+// porting code that still works with interface{} values.
+package typed
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mariomac/manana"
+)
+
+// Future holds the results of an operation that runs asynchronously, in background, and returns
+// a value of type T.
+type Future[T any] interface {
+	// OnSuccess adds a callback to be run when the Future ends with a Success status. The callback
+	// receives the value resulting from the successful operation.
+	OnSuccess(callback func(_ T))
+
+	// OnFail adds a callback to be run when the Future fails with an error status, or when the
+	// Future is canceled with the Cancel() function. The callback receives the error resulting
+	// from the failed operation, or "manana.ErrorCanceled" when the future is canceled.
+	OnFail(callback func(_ error))
+
+	// OnComplete adds a callback to be run indistinctly when the Future succeeds or fails (or
+	// is cancelled). The callback receives the value resulting from the successful operation
+	// (first argument), or the error resulting from the failed operation (second argument).
+	OnComplete(callback func(_ T, _ error))
+
+	// Get makes the invoker goroutine to wait indefinitely until the Future completes, and
+	// returns the value resulting from the successful execution of the Future, or the error
+	// resulting from the failed operation.
+	Get() (T, error)
+
+	// Eventually makes the invoker goroutine to wait until the Future completes, or until the
+	// specified timeout is triggered. In the latter case, it returns manana.ErrorTimeout.
+	Eventually(timeout time.Duration) (T, error)
+
+	// Cancel cancels the future. Canceling a future does not guarantee the goroutine it holds
+	// can be immediately interrupted.
+	Cancel() error
+
+	// IsCompleted returns true if the future is finished, whatever is status is failed, success or
+	// canceled.
+	IsCompleted() bool
+
+	// IsCanceled returns true if the future has been canceled, even if the held goroutine is still
+	// being executed.
+	IsCanceled() bool
+
+	// Untyped returns the manana.Future that backs this typed Future, to be used from code that
+	// still works with the interface{} API.
+	Untyped() manana.Future
+}
+
+// Promise is a typed Future whose Success/Fail status can be set.
+type Promise[T any] interface {
+	Future[T]
+	// Success completes the Promise with a success value passed as argument.
+	Success(value T) error
+	// Fail completes the Promise with an error passed as argument.
+	Fail(err error) error
+	// CancelCtx returns a channel that is closed when the work held in this Promise has to be
+	// canceled.
+	CancelCtx() <-chan struct{}
+}
+
+// TypeError is the error of a typed Future that wraps a manana.Future whose success value can't
+// be converted to the expected type.
+type TypeError struct {
+	// Value is the success value of the wrapped manana.Future
+	Value interface{}
+	// Expected is the name of the type the value was expected to have
+	Expected string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("future value has type %T but %s was expected", e.Value, e.Expected)
+}
+
+type futureImpl[T any] struct {
+	future manana.Future
+}
+
+type promiseImpl[T any] struct {
+	futureImpl[T]
+	promise manana.Promise
+}
+
+// NewPromise creates a new, empty typed promise.
+func NewPromise[T any]() Promise[T] {
+	p := manana.NewPromise()
+	return &promiseImpl[T]{futureImpl: futureImpl[T]{future: p}, promise: p}
+}
+
+// Do wraps a synchronous function into an asynchronous envelope, as manana.Do does, but the
+// success value of the returned Future keeps the type of the wrapped function.
+func Do[T any](syncFunc func() (T, error)) Future[T] {
+	return &futureImpl[T]{future: manana.Do(func() (interface{}, error) {
+		return syncFunc()
+	})}
+}
+
+// DoCtx wraps a cancelable function into an asynchronous envelope, as manana.DoCtx does, but the
+// success value of the returned Future keeps the type of the wrapped function.
+func DoCtx[T any](asyncFunc func(cancelCtx <-chan struct{}) (T, error)) Future[T] {
+	return &futureImpl[T]{future: manana.DoCtx(func(cancelCtx <-chan struct{}) (interface{}, error) {
+		return asyncFunc(cancelCtx)
+	})}
+}
+
+// FromFuture adapts a manana.Future into a typed Future. If the success value of the wrapped
+// future does not have the type T, the returned Future fails with a *TypeError. Canceling the
+// returned Future also cancels the wrapped one.
+func FromFuture[T any](future manana.Future) Future[T] {
+	return derive(future, convert[T])
+}
+
+// derive returns a typed Future that completes when the source future does, converting its
+// success value with the conv function. Cancellation is propagated in both directions.
+func derive[T any](source manana.Future, conv func(interface{}) (T, error)) Future[T] {
+	p := NewPromise[T]()
+	source.OnSuccess(func(value interface{}) {
+		if v, err := conv(value); err != nil {
+			p.Fail(err)
+		} else {
+			p.Success(v)
+		}
+	})
+	source.OnFail(func(err error) {
+		if err == manana.ErrorCanceled {
+			p.Cancel()
+		} else {
+			p.Fail(err)
+		}
+	})
+	p.OnFail(func(err error) {
+		if err == manana.ErrorCanceled {
+			source.Cancel()
+		}
+	})
+	return p
+}
+
+// convert asserts the type of an untyped value. Nil values are converted to the zero value of T.
+func convert[T any](value interface{}) (T, error) {
+	var zero T
+	if value == nil {
+		return zero, nil
+	}
+	v, ok := value.(T)
+	if !ok {
+		return zero, &TypeError{Value: value, Expected: reflect.TypeOf((*T)(nil)).Elem().String()}
+	}
+	return v, nil
+}
+
+// valueOf returns the typed value of a successful future, or the zero value of T if it failed.
+func valueOf[T any](value interface{}) T {
+	v, _ := convert[T](value)
+	return v
+}
+
+func (f *futureImpl[T]) OnSuccess(callback func(_ T)) {
+	f.future.OnSuccess(func(value interface{}) {
+		callback(valueOf[T](value))
+	})
+}
+
+func (f *futureImpl[T]) OnFail(callback func(_ error)) {
+	f.future.OnFail(callback)
+}
+
+func (f *futureImpl[T]) OnComplete(callback func(_ T, _ error)) {
+	f.future.OnComplete(func(value interface{}, err error) {
+		callback(valueOf[T](value), err)
+	})
+}
+
+func (f *futureImpl[T]) Get() (T, error) {
+	value, err := f.future.Get()
+	return valueOf[T](value), err
+}
+
+func (f *futureImpl[T]) Eventually(timeout time.Duration) (T, error) {
+	value, err := f.future.Eventually(timeout)
+	return valueOf[T](value), err
+}
+
+func (f *futureImpl[T]) Cancel() error {
+	return f.future.Cancel()
+}
+
+func (f *futureImpl[T]) IsCompleted() bool {
+	return f.future.IsCompleted()
+}
+
+func (f *futureImpl[T]) IsCanceled() bool {
+	return f.future.IsCanceled()
+}
+
+func (f *futureImpl[T]) Untyped() manana.Future {
+	return f.future
+}
+
+func (p *promiseImpl[T]) Success(value T) error {
+	return p.promise.Success(value)
+}
+
+func (p *promiseImpl[T]) Fail(err error) error {
+	return p.promise.Fail(err)
+}
+
+func (p *promiseImpl[T]) CancelCtx() <-chan struct{} {
+	return p.promise.CancelCtx()
+}
@@ -0,0 +1,181 @@
+package typed
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mariomac/manana"
+	"github.com/stretchr/testify/assert"
+)
+
+func eventually(timeout time.Duration, f func()) error {
+	testFinish := make(chan interface{}, 1)
+	go func() {
+		f()
+		testFinish <- struct{}{}
+	}()
+
+	select {
+	case <-testFinish:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("function hasn't completed after %v", timeout)
+	}
+}
+
+func TestDo_Success(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a synchronous function that returns an int
+		fn := func() (int, error) {
+			return 42, nil
+		}
+		// When executed asynchronously
+		fut := Do(fn)
+
+		// The success value is returned with its type
+		var val int
+		val, err := fut.Get()
+		assert.Equal(t, 42, val)
+		assert.NoError(t, err)
+	}))
+}
+
+func TestDo_Error(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a synchronous function that fails
+		fut := Do(func() (string, error) {
+			return "", errors.New("tracatran")
+		})
+
+		// The error value is properly returned, with the zero value
+		val, err := fut.Get()
+		assert.EqualError(t, err, "tracatran")
+		assert.Equal(t, "", val)
+	}))
+}
+
+func TestDoCtx_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a running cancelable function
+		started := make(chan struct{})
+		canceled := make(chan struct{})
+		fut := DoCtx(func(cancelCtx <-chan struct{}) (int, error) {
+			close(started)
+			<-cancelCtx
+			close(canceled)
+			return 0, manana.ErrorCanceled
+		})
+		<-started
+
+		// When the future is canceled
+		assert.NoError(t, fut.Cancel())
+
+		// The function is notified
+		<-canceled
+		_, err := fut.Get()
+		assert.Equal(t, manana.ErrorCanceled, err)
+		assert.True(t, fut.IsCanceled())
+	}))
+}
+
+func TestFuture_Callbacks(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+
+		// Given a typed promise with success and complete callbacks
+		p := NewPromise[int]()
+		var succeeded, completed int
+		p.OnSuccess(func(v int) {
+			succeeded = v
+			wg.Done()
+		})
+		p.OnComplete(func(v int, err error) {
+			assert.NoError(t, err)
+			completed = v
+			wg.Done()
+		})
+
+		// When the promise succeeds
+		assert.NoError(t, p.Success(3))
+
+		// Both callbacks receive the typed value
+		wg.Wait()
+		assert.Equal(t, 3, succeeded)
+		assert.Equal(t, 3, completed)
+	}))
+}
+
+func TestFromFuture(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given an untyped future
+		untyped := manana.Do(func() (interface{}, error) {
+			return "hello", nil
+		})
+
+		// When it is adapted to a typed future of the right type
+		val, err := FromFuture[string](untyped).Get()
+
+		// The value is correctly converted
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", val)
+
+		// And the typed future can be converted back
+		fut := Do(func() (int, error) { return 5, nil })
+		uval, err := fut.Untyped().Get()
+		assert.NoError(t, err)
+		assert.Equal(t, 5, uval)
+	}))
+}
+
+func TestFromFuture_WrongType(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given an untyped future that returns a string
+		untyped := manana.Do(func() (interface{}, error) {
+			return "hello", nil
+		})
+
+		// When it is adapted to a typed future of another type
+		_, err := FromFuture[int](untyped).Get()
+
+		// The typed future fails instead of panicking
+		assert.IsType(t, &TypeError{}, err)
+		assert.Equal(t, "int", err.(*TypeError).Expected)
+	}))
+}
+
+func TestFromFuture_Cancel(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given a typed future that wraps an untyped promise
+		untyped := manana.NewPromise()
+		fut := FromFuture[int](untyped)
+
+		// When the typed future is canceled
+		assert.NoError(t, fut.Cancel())
+
+		// The wrapped promise is eventually canceled too
+		_, err := untyped.Get()
+		assert.Equal(t, manana.ErrorCanceled, err)
+	}))
+}
+
+func TestAll(t *testing.T) {
+	assert.NoError(t, eventually(2*time.Second, func() {
+		// Given some typed futures
+		futures := []Future[int]{
+			Do(func() (int, error) { return 1, nil }),
+			Do(func() (int, error) { return 2, nil }),
+			Do(func() (int, error) { return 3, nil }),
+		}
+
+		// When all of them are awaited
+		vals, err := All(futures...).Get()
+
+		// A typed slice with the results is returned
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, vals)
+	}))
+}
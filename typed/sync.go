@@ -0,0 +1,21 @@
+package typed
+
+import "github.com/mariomac/manana"
+
+// All returns a future that will succeed when all the argument futures succeed, as manana.All
+// does. If the returned future succeeds, the return value is a slice containing the success
+// values of all the parameter futures, in the same order as they are passed to the All function.
+func All[T any](futures ...Future[T]) Future[[]T] {
+	untyped := make([]manana.Future, len(futures))
+	for i, f := range futures {
+		untyped[i] = f.Untyped()
+	}
+	return derive(manana.All(untyped...), func(value interface{}) ([]T, error) {
+		values := value.([]interface{})
+		results := make([]T, len(values))
+		for i, v := range values {
+			results[i] = valueOf[T](v)
+		}
+		return results, nil
+	})
+}